@@ -0,0 +1,54 @@
+package config
+
+// Config 是全局配置结构体。本次改动只关心 logic/event 包新引入的 Sync、Webhooks 两个
+// 配置块，以及既有代码已经在用的 Ldap.GroupNameModify/UserNameModify、
+// System.AppIsRelease；仓库里真正的配置文件还有数据库、LDAP 连接等大量其它字段，
+// 这份阉割过的代码快照里不存在，这里不去伪造，只补全让本次新增代码能通过编译的部分。
+type Config struct {
+	Ldap     LdapConfig
+	System   SystemConfig
+	Sync     SyncConfig
+	Webhooks []WebhookConfig
+}
+
+// LdapConfig 对应既有代码里使用的 config.Conf.Ldap.* 开关。
+type LdapConfig struct {
+	// GroupNameModify 为 false 时，CommonUpdateGroup 不允许通过同步/编辑修改分组名称。
+	GroupNameModify bool
+	// UserNameModify 为 false 时，CommonUpdateUser 不允许通过同步/编辑修改用户名。
+	UserNameModify bool
+}
+
+// SystemConfig 对应既有代码里使用的 config.Conf.System.* 开关。
+type SystemConfig struct {
+	// AppIsRelease 为 false 时允许用 SYNC_TEST_SCHEDULE 环境变量覆盖同步任务的 cron 表达式。
+	AppIsRelease bool
+}
+
+// SyncConfig 配置 SyncSource 的调度与同步结果通知。
+type SyncConfig struct {
+	// Sources 以 SyncSource.Flag() 为 key（如 "dingtalk"/"wecom"/"feishu"），
+	// 配置每个上游源是否启用、部门/用户各自的 cron 表达式。
+	Sources map[string]SyncSourceConfig
+	// NotifyWebhooks 以上游标识为 key（如 "feishu"/"dingtalk"/"wecom"），
+	// 配置同步完成后推送结果卡片所用的机器人 webhook 地址；未配置的平台不会注册通知。
+	NotifyWebhooks map[string]string
+}
+
+// SyncSourceConfig 是单个上游源的调度配置，DeptCron/UserCron 留空时使用
+// SyncSource.DefaultSchedule() 提供的默认表达式。
+type SyncSourceConfig struct {
+	Enabled  bool
+	DeptCron string
+	UserCron string
+}
+
+// WebhookConfig 描述一个通用 webhook 订阅方，对应 event.LoadWebhookSubscribers。
+type WebhookConfig struct {
+	URL    string
+	Secret string
+	Events []string
+}
+
+// Conf 是进程内唯一的全局配置实例，由启动时的配置加载逻辑填充。
+var Conf = new(Config)
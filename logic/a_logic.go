@@ -1,17 +1,20 @@
 package logic
 
 import (
+	"context"
 	"fmt"
+	"os"
+	"strings"
 
 	"github.com/eryajf/go-ldap-admin/config"
+	"github.com/eryajf/go-ldap-admin/logic/event"
+	"github.com/eryajf/go-ldap-admin/logic/tx"
 	"github.com/eryajf/go-ldap-admin/model"
 	"github.com/eryajf/go-ldap-admin/public/common"
 	"github.com/eryajf/go-ldap-admin/public/tools"
-	"github.com/eryajf/go-ldap-admin/service/ildap"
 	"github.com/eryajf/go-ldap-admin/service/isql"
 	jsoniter "github.com/json-iterator/go"
 	"github.com/robfig/cron/v3"
-	"github.com/tidwall/gjson"
 )
 
 var (
@@ -33,54 +36,50 @@ var (
 	json = jsoniter.ConfigCompatibleWithStandardLibrary
 )
 
-// CommonAddGroup 标准创建分组
+// CommonAddGroup 标准创建分组：LDAP、MySQL 两个写入动作组装成一个 tx.Bundle 执行，
+// 任意一步失败都会按逆序补偿已经成功的步骤，不会出现分组只建在一边的情况。
 func CommonAddGroup(group *model.Group) error {
-	// 先在ldap中创建组
-	err := ildap.Group.Add(group)
-	if err != nil {
-		return err
-	}
-
-	// 然后在数据库中创建组
-	err = isql.Group.Add(group)
-	if err != nil {
-		return err
-	}
-
-	// 默认创建分组之后，需要将admin添加到分组中
 	adminInfo := new(model.User)
-	err = isql.User.Find(tools.H{"id": 1}, adminInfo)
+	err := isql.User.Find(tools.H{"id": 1}, adminInfo)
 	if err != nil {
 		return err
 	}
 
-	err = isql.Group.AddUserToGroup(group, []model.User{*adminInfo})
-	if err != nil {
+	// mysql.* 操作先全部入队、ldap.* 操作再入队，这样 Execute 才能把连续的 mysql 操作
+	// 合并进同一个 gorm 事务；交替排列会让每个事务段只剩一条语句，起不到原子提交的作用。
+	bundle := tx.NewBundle().
+		Add(tx.MysqlGroupAdd(group)).
+		// 默认创建分组之后，需要将admin添加到分组中
+		Add(tx.MysqlGroupAddMember(group, []model.User{*adminInfo})).
+		Add(tx.LdapGroupAdd(group))
+
+	if err := tx.Execute(bundle, OperationLog); err != nil {
 		return err
 	}
 
+	event.Publish(event.GroupCreated{Group: group})
 	return nil
 }
 
-// CommonUpdateGroup 标准更新分组
+// CommonUpdateGroup 标准更新分组。写入时以 oldGroup.Version 做乐观并发的 CAS 校验
+// （见 tx.MysqlGroupUpdate/isql.UpdateGroupCAS），数据已被别的请求改过时返回
+// tools.ErrStaleWrite，调用方（定时同步）可以重新查询后重试。
 func CommonUpdateGroup(oldGroup, newGroup *model.Group) error {
 	//若配置了不允许修改分组名称，则不更新分组名称
 	if !config.Conf.Ldap.GroupNameModify {
 		newGroup.GroupName = oldGroup.GroupName
 	}
 
-	err := ildap.Group.Update(oldGroup, newGroup)
-	if err != nil {
-		return err
-	}
-	err = isql.Group.Update(newGroup)
-	if err != nil {
-		return err
-	}
-	return nil
+	// mysql.* 先入队、ldap.* 后入队，原因同 CommonAddGroup。
+	bundle := tx.NewBundle().
+		Add(tx.MysqlGroupUpdate(oldGroup, newGroup)).
+		Add(tx.LdapGroupUpdate(oldGroup, newGroup))
+
+	return tx.Execute(bundle, OperationLog)
 }
 
-// CommonAddUser 标准创建用户
+// CommonAddUser 标准创建用户：将 MySQL/LDAP 写入以及部门归属都组装进同一个 tx.Bundle，
+// 任意一步失败都会按逆序回滚已经成功的步骤。
 func CommonAddUser(user *model.User, groups []*model.Group) error {
 	// 用户信息的预置处理
 	if user.Nickname == "" {
@@ -111,53 +110,49 @@ func CommonAddUser(user *model.User, groups []*model.Group) error {
 		user.Mobile = "emptyMobile"
 	}
 
-	// 先将用户添加到MySQL
-	err := isql.User.Add(user)
-	if err != nil {
-		return tools.NewMySqlError(fmt.Errorf("向MySQL创建用户失败：" + err.Error()))
-	}
-	// 再将用户添加到ldap
-	err = ildap.User.Add(user)
-	if err != nil {
-		return tools.NewLdapError(fmt.Errorf("AddUser向LDAP创建用户失败：" + err.Error()))
-	}
-
 	// 处理用户归属的组
+	var memberGroups []*model.Group
 	for _, group := range groups {
 		if group.GroupDN[:3] == "ou=" {
 			continue
 		}
-		// 先将用户和部门信息维护到MySQL
-		err := isql.Group.AddUserToGroup(group, []model.User{*user})
-		if err != nil {
-			return tools.NewMySqlError(fmt.Errorf("向MySQL添加用户到分组关系失败：" + err.Error()))
-		}
-		//根据选择的部门，添加到部门内
-		err = ildap.Group.AddUserToGroup(group.GroupDN, user.UserDN)
-		if err != nil {
-			return tools.NewMySqlError(fmt.Errorf("向Ldap添加用户到分组关系失败：" + err.Error()))
-		}
+		group := group
+		memberGroups = append(memberGroups, group)
+	}
+
+	// mysql.* 操作全部先入队，ldap.* 操作再入队：这样用户行本身以及它归属的每个分组
+	// 成员关系这几条 mysql 写入会被 Execute 合并进同一个事务原子提交，不会跟中间穿插的
+	// ldap 操作打断成一条条各自独立的单语句事务。
+	bundle := tx.NewBundle().
+		Add(tx.MysqlUserAdd(user))
+	for _, group := range memberGroups {
+		bundle.Add(tx.MysqlGroupAddMember(group, []model.User{*user}))
+	}
+	bundle.Add(tx.LdapUserAdd(user))
+	for _, group := range memberGroups {
+		bundle.Add(tx.LdapGroupAddMember(group.GroupDN, user.UserDN))
+	}
+
+	if err := tx.Execute(bundle, OperationLog); err != nil {
+		return err
+	}
+
+	event.Publish(event.UserCreated{User: user})
+	for _, group := range memberGroups {
+		event.Publish(event.GroupMembershipAdded{Group: group, User: user})
 	}
 	return nil
 }
 
-// CommonUpdateUser 标准更新用户
+// CommonUpdateUser 标准更新用户。写入时以 oldUser.Version 做乐观并发的 CAS 校验
+// （见 tx.MysqlUserUpdate/isql.UpdateUserCAS），数据已被别的请求改过时返回
+// tools.ErrStaleWrite；钉钉/企微/飞书的定时同步在 updateUserWithRetry 里遇到
+// ErrStaleWrite 会重新查询最新数据后重试，重试次数有上限（见 logic/sync_client.go）。
 func CommonUpdateUser(oldUser, newUser *model.User, groupId []uint) error {
-	// 更新用户
 	if !config.Conf.Ldap.UserNameModify {
 		newUser.Username = oldUser.Username
 	}
 
-	err := ildap.User.Update(oldUser.Username, newUser)
-	if err != nil {
-		return tools.NewLdapError(fmt.Errorf("在LDAP更新用户失败：" + err.Error()))
-	}
-
-	err = isql.User.Update(newUser)
-	if err != nil {
-		return tools.NewMySqlError(fmt.Errorf("在MySQL更新用户失败：" + err.Error()))
-	}
-
 	//判断部门信息是否有变化有变化则更新相应的数据库
 	oldDeptIds := tools.StringToSlice(oldUser.DepartmentId, ",")
 	addDeptIds, removeDeptIds := tools.ArrUintCmp(oldDeptIds, groupId)
@@ -167,20 +162,13 @@ func CommonUpdateUser(oldUser, newUser *model.User, groupId []uint) error {
 	if err != nil {
 		return tools.NewMySqlError(fmt.Errorf("根据部门ID获取部门信息失败" + err.Error()))
 	}
+	var addedGroups []*model.Group
 	for _, group := range addgroups {
+		group := group
 		if group.GroupDN[:3] == "ou=" {
 			continue
 		}
-		// 先将用户和部门信息维护到MySQL
-		err := isql.Group.AddUserToGroup(group, []model.User{*newUser})
-		if err != nil {
-			return tools.NewMySqlError(fmt.Errorf("向MySQL添加用户到分组关系失败：" + err.Error()))
-		}
-		//根据选择的部门，添加到部门内
-		err = ildap.Group.AddUserToGroup(group.GroupDN, newUser.UserDN)
-		if err != nil {
-			return tools.NewLdapError(fmt.Errorf("向Ldap添加用户到分组关系失败：" + err.Error()))
-		}
+		addedGroups = append(addedGroups, group)
 	}
 
 	// 再处理删除的部门
@@ -188,23 +176,52 @@ func CommonUpdateUser(oldUser, newUser *model.User, groupId []uint) error {
 	if err != nil {
 		return tools.NewMySqlError(fmt.Errorf("根据部门ID获取部门信息失败" + err.Error()))
 	}
+	var removedGroups []*model.Group
 	for _, group := range removegroups {
+		group := group
 		if group.GroupDN[:3] == "ou=" {
 			continue
 		}
-		err := isql.Group.RemoveUserFromGroup(group, []model.User{*newUser})
-		if err != nil {
-			return tools.NewMySqlError(fmt.Errorf("在MySQL将用户从分组移除失败：" + err.Error()))
-		}
-		err = ildap.Group.RemoveUserFromGroup(group.GroupDN, newUser.UserDN)
-		if err != nil {
-			return tools.NewMySqlError(fmt.Errorf("在ldap将用户从分组移除失败：" + err.Error()))
-		}
+		removedGroups = append(removedGroups, group)
+	}
+
+	// mysql.* 操作全部先入队、ldap.* 操作再入队，原因同 CommonAddUser：让用户行与它的
+	// 分组成员关系变更合并进同一个 mysql 事务，而不是被中间穿插的 ldap 操作打断。
+	bundle := tx.NewBundle().
+		Add(tx.MysqlUserUpdate(oldUser, newUser))
+	for _, group := range addedGroups {
+		bundle.Add(tx.MysqlGroupAddMember(group, []model.User{*newUser}))
+	}
+	for _, group := range removedGroups {
+		bundle.Add(tx.MysqlGroupRemoveMember(group, []model.User{*newUser}))
+	}
+	bundle.Add(tx.LdapUserUpdate(oldUser, newUser))
+	for _, group := range addedGroups {
+		bundle.Add(tx.LdapGroupAddMember(group.GroupDN, newUser.UserDN))
+	}
+	for _, group := range removedGroups {
+		bundle.Add(tx.LdapGroupRemoveMember(group.GroupDN, newUser.UserDN))
+	}
+
+	if err := tx.Execute(bundle, OperationLog); err != nil {
+		return err
+	}
+
+	if diff := diffUserFields(oldUser, newUser); len(diff) > 0 {
+		event.Publish(event.UserUpdated{User: newUser, Diff: diff})
+	}
+	for _, group := range addedGroups {
+		event.Publish(event.GroupMembershipAdded{Group: group, User: newUser})
+	}
+	for _, group := range removedGroups {
+		event.Publish(event.GroupMembershipRemoved{Group: group, User: newUser})
 	}
 	return nil
 }
 
-// BuildGroupData 根据数据与动态字段组装成分组数据
+// BuildGroupData 根据数据与动态字段组装成分组数据。FieldRelation.Attributes 中每个字段的取值
+// 既可以是普通的 gjson 路径字符串（向后兼容），也可以是 tools.EvalFieldMapping 支持的表达式对象，
+// 例如 coalesce 多个上游字段、正则替换、模板拼接等，具体见 public/tools/field_expr.go。
 func BuildGroupData(flag string, remoteData map[string]interface{}) (*model.Group, error) {
 	output, err := json.Marshal(&remoteData)
 	if err != nil {
@@ -216,28 +233,32 @@ func BuildGroupData(flag string, remoteData map[string]interface{}) (*model.Grou
 	if err != nil {
 		return nil, tools.NewMySqlError(err)
 	}
-	frs, err := tools.JsonToMap(string(oldData.Attributes))
-	if err != nil {
+	rawAttrs := make(map[string]interface{})
+	if err := json.Unmarshal([]byte(oldData.Attributes), &rawAttrs); err != nil {
 		return nil, tools.NewOperationError(err)
 	}
 
 	g := &model.Group{}
-	for system, remote := range frs {
+	for system, remote := range rawAttrs {
+		val, err := tools.EvalFieldMapping(remote, string(output))
+		if err != nil {
+			return nil, tools.NewOperationError(fmt.Errorf("字段 %s 的映射配置解析失败：%w", system, err))
+		}
 		switch system {
 		case "groupName":
-			g.SetGroupName(gjson.Get(string(output), remote).String())
+			g.SetGroupName(val)
 		case "remark":
-			g.SetRemark(gjson.Get(string(output), remote).String())
+			g.SetRemark(val)
 		case "sourceDeptId":
-			g.SetSourceDeptId(fmt.Sprintf("%s_%s", flag, gjson.Get(string(output), remote).String()))
+			g.SetSourceDeptId(fmt.Sprintf("%s_%s", flag, val))
 		case "sourceDeptParentId":
-			g.SetSourceDeptParentId(fmt.Sprintf("%s_%s", flag, gjson.Get(string(output), remote).String()))
+			g.SetSourceDeptParentId(fmt.Sprintf("%s_%s", flag, val))
 		}
 	}
 	return g, nil
 }
 
-// BuildUserData 根据数据与动态字段组装成用户数据
+// BuildUserData 根据数据与动态字段组装成用户数据。字段映射支持的语法见 BuildGroupData 的说明。
 func BuildUserData(flag string, remoteData map[string]interface{}) (*model.User, error) {
 	output, err := json.Marshal(&remoteData)
 	if err != nil {
@@ -249,38 +270,42 @@ func BuildUserData(flag string, remoteData map[string]interface{}) (*model.User,
 	if err != nil {
 		return nil, tools.NewMySqlError(err)
 	}
-	fieldRelation, err := tools.JsonToMap(string(fieldRelationSource.Attributes))
-	if err != nil {
+	rawAttrs := make(map[string]interface{})
+	if err := json.Unmarshal([]byte(fieldRelationSource.Attributes), &rawAttrs); err != nil {
 		return nil, tools.NewOperationError(err)
 	}
 
 	u := &model.User{}
-	for system, remote := range fieldRelation {
+	for system, remote := range rawAttrs {
+		val, err := tools.EvalFieldMapping(remote, string(output))
+		if err != nil {
+			return nil, tools.NewOperationError(fmt.Errorf("字段 %s 的映射配置解析失败：%w", system, err))
+		}
 		switch system {
 		case "username":
-			u.SetUserName(gjson.Get(string(output), remote).String())
+			u.SetUserName(val)
 		case "nickname":
-			u.SetNickName(gjson.Get(string(output), remote).String())
+			u.SetNickName(val)
 		case "givenName":
-			u.SetGivenName(gjson.Get(string(output), remote).String())
+			u.SetGivenName(val)
 		case "mail":
-			u.SetMail(gjson.Get(string(output), remote).String())
+			u.SetMail(val)
 		case "jobNumber":
-			u.SetJobNumber(gjson.Get(string(output), remote).String())
+			u.SetJobNumber(val)
 		case "mobile":
-			u.SetMobile(gjson.Get(string(output), remote).String())
+			u.SetMobile(val)
 		case "avatar":
-			u.SetAvatar(gjson.Get(string(output), remote).String())
+			u.SetAvatar(val)
 		case "postalAddress":
-			u.SetPostalAddress(gjson.Get(string(output), remote).String())
+			u.SetPostalAddress(val)
 		case "position":
-			u.SetPosition(gjson.Get(string(output), remote).String())
+			u.SetPosition(val)
 		case "introduction":
-			u.SetIntroduction(gjson.Get(string(output), remote).String())
+			u.SetIntroduction(val)
 		case "sourceUserId":
-			u.SetSourceUserId(fmt.Sprintf("%s_%s", flag, gjson.Get(string(output), remote).String()))
+			u.SetSourceUserId(fmt.Sprintf("%s_%s", flag, val))
 		case "sourceUnionId":
-			u.SetSourceUnionId(fmt.Sprintf("%s_%s", flag, gjson.Get(string(output), remote).String()))
+			u.SetSourceUnionId(fmt.Sprintf("%s_%s", flag, val))
 		}
 	}
 	return u, nil
@@ -315,59 +340,60 @@ func ConvertUserData(flag string, remoteData []map[string]interface{}) (users []
 	return
 }
 
+// InitCron 初始化所有已注册上游源（SyncSource）的定时同步任务。每个源是否启用、部门/用户的
+// cron 表达式都来自 config.Conf.Sync.Sources[flag]，不再像过去那样把钉钉/企微/飞书的分支和
+// cron 表达式硬编码在这里；新增一个上游源只需要实现 SyncSource 并调用 RegisterSyncSource。
+// 非 Release 环境下，设置环境变量 SYNC_TEST_SCHEDULE（如 "*/1 * * * *"）可以让所有源都按这个
+// 高频表达式跑，方便联调验证同步逻辑。
 func InitCron() {
+	// 注册 webhook/内置通知订阅方，并把最终投递失败的事件接到 MySQL 死信表；
+	// 这是进程启动时唯一会跑到的初始化入口，event 包那一侧否则永远不会被接上线。
+	InitEventSystem()
+
 	c := cron.New(cron.WithSeconds())
+	testSpec := ""
+	if !config.Conf.System.AppIsRelease {
+		testSpec = os.Getenv("SYNC_TEST_SCHEDULE")
+		// c 按带秒字段的 6 段表达式解析，5 段表达式在前面补上秒字段 "0"。
+		if len(strings.Fields(testSpec)) == 5 {
+			testSpec = "0 " + testSpec
+		}
+	}
 
-	if config.Conf.DingTalk.EnableSync {
-		//启动定时任务
-		_, err := c.AddFunc("0 1 5 * * *", func() {
-			common.Log.Info("每天凌晨5点1分0秒执行一次同步钉钉部门信息到ldap")
-			DingTalk.SyncDingTalkDepts(nil, nil)
-		})
-		if err != nil {
-			common.Log.Errorf("启动同步部门的定时任务失败: %v", err)
+	for flag, source := range syncSourcesSnapshot() {
+		flag, source := flag, source
+		cfg, ok := config.Conf.Sync.Sources[flag]
+		if !ok || !cfg.Enabled {
+			continue
 		}
-		//每天凌晨1点执行一次
-		_, err = c.AddFunc("0 30 5 * * *", func() {
-			common.Log.Info("每天凌晨5点30分执行一次同步钉钉用户信息到ldap")
-			DingTalk.SyncDingTalkUsers(nil, nil)
-		})
-		if err != nil {
-			common.Log.Errorf("启动同步用户的定时任务失败: %v", err)
+		deptSpec, userSpec := cfg.DeptCron, cfg.UserCron
+		defDeptSpec, defUserSpec := source.DefaultSchedule()
+		if deptSpec == "" {
+			deptSpec = defDeptSpec
 		}
-	}
-	if config.Conf.WeCom.EnableSync {
-		_, err := c.AddFunc("0 1 5 * * *", func() {
-			common.Log.Info("每天凌晨5点1分0秒执行一次同步企业微信部门信息到ldap")
-			WeCom.SyncWeComDepts(nil, nil)
-		})
-		if err != nil {
-			common.Log.Errorf("启动同步部门的定时任务失败: %v", err)
+		if userSpec == "" {
+			userSpec = defUserSpec
 		}
-		//每天凌晨1点执行一次
-		_, err = c.AddFunc("0 30 5 * * *", func() {
-			common.Log.Info("每天凌晨5点30分执行一次同步企业微信用户信息到ldap")
-			WeCom.SyncWeComUsers(nil, nil)
-		})
-		if err != nil {
-			common.Log.Errorf("启动同步用户的定时任务失败: %v", err)
+		if testSpec != "" {
+			deptSpec, userSpec = testSpec, testSpec
 		}
-	}
-	if config.Conf.FeiShu.EnableSync {
-		_, err := c.AddFunc("0 1 5 * * *", func() {
-			common.Log.Info("每天凌晨5点1分0秒执行一次同步飞书部门信息到ldap")
-			FeiShu.SyncFeiShuDepts(nil, nil)
-		})
-		if err != nil {
-			common.Log.Errorf("启动同步部门的定时任务失败: %v", err)
+
+		if _, err := c.AddFunc(deptSpec, func() {
+			common.Log.Infof("开始执行%s部门信息同步任务", flag)
+			if err := source.SyncDepts(context.Background(), nil, false); err != nil {
+				common.Log.Errorf("同步%s部门信息失败: %v", flag, err)
+			}
+		}); err != nil {
+			common.Log.Errorf("启动同步%s部门的定时任务失败: %v", flag, err)
 		}
-		//每天凌晨1点执行一次
-		_, err = c.AddFunc("0 30 5 * * *", func() {
-			common.Log.Info("每天凌晨5点30分执行一次同步飞书用户信息到ldap")
-			FeiShu.SyncFeiShuUsers(nil, nil)
-		})
-		if err != nil {
-			common.Log.Errorf("启动同步用户的定时任务失败: %v", err)
+
+		if _, err := c.AddFunc(userSpec, func() {
+			common.Log.Infof("开始执行%s用户信息同步任务", flag)
+			if err := source.SyncUsers(context.Background(), nil, false); err != nil {
+				common.Log.Errorf("同步%s用户信息失败: %v", flag, err)
+			}
+		}); err != nil {
+			common.Log.Errorf("启动同步%s用户的定时任务失败: %v", flag, err)
 		}
 	}
 	c.Start()
@@ -0,0 +1,26 @@
+package logic
+
+import (
+	"context"
+
+	"github.com/eryajf/go-ldap-admin/model"
+)
+
+// DingTalkLogic 封装钉钉通讯录同步的业务逻辑。实际请求钉钉 OpenAPI 的客户端通过
+// Client 字段注入，便于替换测试桩；未注入时 SyncDingTalkDepts/SyncDingTalkUsers
+// 会直接返回错误，而不是 panic。
+type DingTalkLogic struct {
+	Client UpstreamClient
+}
+
+// SyncDingTalkDepts 同步钉钉部门数据，taskLog 不为 nil 时会实时追加本次同步的进度文本
+// （由 TriggerSync 在手动触发时创建并持续落库；cron 定时同步传 nil 即可）；dryRun 为 true
+// 时只生成预览计划（见 BuildSyncPlan），不写入 LDAP/MySQL。
+func (l *DingTalkLogic) SyncDingTalkDepts(ctx context.Context, taskLog *model.SyncTaskLog, dryRun bool) error {
+	return runSyncDepts(ctx, "dingtalk", l.Client, taskLog, dryRun)
+}
+
+// SyncDingTalkUsers 同步钉钉用户数据，语义同 SyncDingTalkDepts。
+func (l *DingTalkLogic) SyncDingTalkUsers(ctx context.Context, taskLog *model.SyncTaskLog, dryRun bool) error {
+	return runSyncUsers(ctx, "dingtalk", l.Client, taskLog, dryRun)
+}
@@ -0,0 +1,243 @@
+// Package event 提供用户/分组/同步任务生命周期事件的发布与订阅能力，
+// 让 Webhook 等外部消费方能感知到数据变化，而不必再去翻 InitCron 的日志。
+package event
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/eryajf/go-ldap-admin/config"
+	"github.com/eryajf/go-ldap-admin/model"
+	"github.com/eryajf/go-ldap-admin/public/common"
+)
+
+// Type 标识事件种类。
+type Type string
+
+const (
+	TypeUserCreated            Type = "user.created"
+	TypeUserUpdated            Type = "user.updated"
+	TypeUserDeleted            Type = "user.deleted"
+	TypeGroupCreated           Type = "group.created"
+	TypeGroupMembershipAdded   Type = "group.membership_added"
+	TypeGroupMembershipRemoved Type = "group.membership_removed"
+	TypeSyncStarted            Type = "sync.started"
+	TypeSyncFinished           Type = "sync.finished"
+)
+
+// Event 是发布到事件总线上的统一信封，Payload 为各事件类型自身的结构体。
+type Event struct {
+	Type    Type        `json:"type"`
+	Payload interface{} `json:"payload"`
+}
+
+// UserCreated 对应 CommonAddUser 成功之后触发的事件。
+type UserCreated struct {
+	User *model.User `json:"user"`
+}
+
+// UserUpdated 对应 CommonUpdateUser 成功之后触发的事件，Diff 为发生变化的字段。
+type UserUpdated struct {
+	User *model.User         `json:"user"`
+	Diff []model.FieldChange `json:"diff"`
+}
+
+// UserDeleted 对应用户被删除时触发的事件。
+type UserDeleted struct {
+	User *model.User `json:"user"`
+}
+
+// GroupCreated 对应 CommonAddGroup 成功之后触发的事件。
+type GroupCreated struct {
+	Group *model.Group `json:"group"`
+}
+
+// GroupMembershipAdded 对应用户被加入分组时触发的事件。
+type GroupMembershipAdded struct {
+	Group *model.Group `json:"group"`
+	User  *model.User  `json:"user"`
+}
+
+// GroupMembershipRemoved 对应用户被移出分组时触发的事件。
+type GroupMembershipRemoved struct {
+	Group *model.Group `json:"group"`
+	User  *model.User  `json:"user"`
+}
+
+// SyncStats 统计一次同步新增/更新/删除的数量。
+type SyncStats struct {
+	Creates int `json:"creates"`
+	Updates int `json:"updates"`
+	Deletes int `json:"deletes"`
+}
+
+// SyncStarted 对应一次上游同步开始时触发的事件。
+type SyncStarted struct {
+	Flag string `json:"flag"`
+}
+
+// SyncFinished 对应一次上游同步结束时触发的事件。
+type SyncFinished struct {
+	Flag   string    `json:"flag"`
+	Stats  SyncStats `json:"stats"`
+	Errors []string  `json:"errors,omitempty"`
+}
+
+// Subscriber 是一个事件消费方，来自 config.Conf.Webhooks 配置或内置实现
+// （例如飞书/钉钉/企微的每日同步结果通知）。
+type Subscriber interface {
+	// Interested 返回该订阅方关心的事件类型；为空表示关心全部事件。
+	Interested() []Type
+	// Handle 处理一个事件，返回的 error 会触发退避重试，最终仍失败则进入死信队列。
+	Handle(e Event) error
+}
+
+var subscribers []Subscriber
+
+// Subscribe 注册一个订阅方，通常在各订阅方自己的初始化逻辑中调用。
+func Subscribe(s Subscriber) {
+	subscribers = append(subscribers, s)
+}
+
+// Publish 将事件异步分发给所有感兴趣的订阅方（每个订阅方各起一个 goroutine 投递，Publish 不等待投递结果）；
+// 订阅方投递失败只记录日志、不影响调用方主流程。
+func Publish(payload interface{}) {
+	t := typeOf(payload)
+	if t == "" {
+		common.Log.Errorf("event.Publish 收到未知类型的事件：%#v", payload)
+		return
+	}
+	e := Event{Type: t, Payload: payload}
+	for _, s := range subscribers {
+		if !interested(s, t) {
+			continue
+		}
+		go deliver(s, e)
+	}
+}
+
+func interested(s Subscriber, t Type) bool {
+	types := s.Interested()
+	if len(types) == 0 {
+		return true
+	}
+	for _, it := range types {
+		if it == t {
+			return true
+		}
+	}
+	return false
+}
+
+func typeOf(payload interface{}) Type {
+	switch payload.(type) {
+	case UserCreated:
+		return TypeUserCreated
+	case UserUpdated:
+		return TypeUserUpdated
+	case UserDeleted:
+		return TypeUserDeleted
+	case GroupCreated:
+		return TypeGroupCreated
+	case GroupMembershipAdded:
+		return TypeGroupMembershipAdded
+	case GroupMembershipRemoved:
+		return TypeGroupMembershipRemoved
+	case SyncStarted:
+		return TypeSyncStarted
+	case SyncFinished:
+		return TypeSyncFinished
+	default:
+		return ""
+	}
+}
+
+const (
+	maxDeliverAttempts = 5
+	initialBackoff     = time.Second
+)
+
+// deliver 按指数退避重试投递一个事件，最终仍失败则写入死信队列。
+func deliver(s Subscriber, e Event) {
+	backoff := initialBackoff
+	var lastErr error
+	for attempt := 1; attempt <= maxDeliverAttempts; attempt++ {
+		if err := s.Handle(e); err != nil {
+			lastErr = err
+			common.Log.Warnf("事件 %s 第 %d 次投递失败：%v", e.Type, attempt, err)
+			time.Sleep(backoff)
+			backoff *= 2
+			continue
+		}
+		return
+	}
+	deadLetter(e, lastErr)
+}
+
+// webhookSubscriber 把事件以 HMAC-SHA256 签名的 JSON POST 投递到配置的 URL，实现 at-least-once 投递。
+type webhookSubscriber struct {
+	url    string
+	secret string
+	events []Type
+}
+
+func (w *webhookSubscriber) Interested() []Type { return w.events }
+
+func (w *webhookSubscriber) Handle(e Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if w.secret != "" {
+		mac := hmac.New(sha256.New, []byte(w.secret))
+		mac.Write(body)
+		req.Header.Set("X-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook 返回状态码 %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// LoadWebhookSubscribers 依据 config.Conf.Webhooks 注册 webhook 订阅方，在程序启动时调用一次。
+func LoadWebhookSubscribers() {
+	for _, w := range config.Conf.Webhooks {
+		events := make([]Type, 0, len(w.Events))
+		for _, name := range w.Events {
+			events = append(events, Type(name))
+		}
+		Subscribe(&webhookSubscriber{url: w.URL, secret: w.Secret, events: events})
+	}
+}
+
+// deadLetterSink 由调用方注入，负责把最终投递失败的事件落盘（例如写入 MySQL 死信表），
+// event 包本身不直接依赖 isql，避免引入循环依赖。
+var deadLetterSink func(e Event, err error)
+
+// SetDeadLetterSink 注入死信处理逻辑。
+func SetDeadLetterSink(sink func(e Event, err error)) {
+	deadLetterSink = sink
+}
+
+func deadLetter(e Event, err error) {
+	common.Log.Errorf("事件 %s 投递最终失败，进入死信队列：%v", e.Type, err)
+	if deadLetterSink != nil {
+		deadLetterSink(e, err)
+	}
+}
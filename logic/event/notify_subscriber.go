@@ -0,0 +1,81 @@
+package event
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/eryajf/go-ldap-admin/config"
+)
+
+// imCardSubscriber 在 SyncFinished 时推送一条同步结果汇总的 markdown 卡片，
+// 让运维无需翻 InitCron 日志就能看到每日同步的增删改统计。钉钉/企微与飞书的
+// 卡片消息格式并不通用，platform 决定 payload() 按哪种格式拼 body。
+type imCardSubscriber struct {
+	platform string
+	url      string
+}
+
+func (s *imCardSubscriber) Interested() []Type { return []Type{TypeSyncFinished} }
+
+// payload 按平台拼出各自期望的卡片消息格式：钉钉/企微用 msgtype+markdown，
+// 飞书用 msg_type=interactive 的 card 结构，两者字段完全不同，不能混用。
+func (s *imCardSubscriber) payload(text string) map[string]interface{} {
+	switch s.platform {
+	case "dingtalk", "wecom":
+		return map[string]interface{}{
+			"msgtype": "markdown",
+			"markdown": map[string]interface{}{
+				"title": "同步完成",
+				"text":  text,
+			},
+		}
+	default: // feishu
+		return map[string]interface{}{
+			"msg_type": "interactive",
+			"card": map[string]interface{}{
+				"elements": []map[string]interface{}{
+					{"tag": "markdown", "content": text},
+				},
+			},
+		}
+	}
+}
+
+func (s *imCardSubscriber) Handle(e Event) error {
+	sf, ok := e.Payload.(SyncFinished)
+	if !ok {
+		return nil
+	}
+	text := fmt.Sprintf("**%s 同步完成**\n- 新增：%d\n- 更新：%d\n- 删除：%d", sf.Flag, sf.Stats.Creates, sf.Stats.Updates, sf.Stats.Deletes)
+	if len(sf.Errors) > 0 {
+		text += fmt.Sprintf("\n- 错误：%d 条", len(sf.Errors))
+	}
+	body, err := json.Marshal(s.payload(text))
+	if err != nil {
+		return err
+	}
+	resp, err := http.Post(s.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s 卡片推送返回状态码 %d", s.platform, resp.StatusCode)
+	}
+	return nil
+}
+
+// LoadSyncNotifySubscriber 注册内置的同步结果通知订阅方，遍历
+// config.Conf.Sync.NotifyWebhooks（平台名 -> webhook url），为每个配置了 url
+// 的平台单独订阅一个 imCardSubscriber，从而真正支持飞书/钉钉/企微三种卡片格式，
+// 而不是只按飞书格式拼一份 body 发给所有平台。
+func LoadSyncNotifySubscriber() {
+	for platform, url := range config.Conf.Sync.NotifyWebhooks {
+		if url == "" {
+			continue
+		}
+		Subscribe(&imCardSubscriber{platform: platform, url: url})
+	}
+}
@@ -0,0 +1,35 @@
+package logic
+
+import (
+	"github.com/eryajf/go-ldap-admin/logic/event"
+	"github.com/eryajf/go-ldap-admin/model"
+	"github.com/eryajf/go-ldap-admin/public/common"
+	"github.com/eryajf/go-ldap-admin/service/isql"
+)
+
+// InitEventSystem 注册 config.Conf.Webhooks 配置的 webhook 订阅方、内置的飞书/钉钉/企微
+// 同步结果通知，并把最终投递失败的事件接到 MySQL 死信表。event 包本身不直接依赖 isql
+// （一个通用的事件总线不该绑死在某一种存储实现上），这里是它与 MySQL 之间唯一的接线点，
+// 由 InitCron 在进程启动时调用一次；不调用这个函数的话，上面这些订阅方、死信落库
+// 都只是定义了却永远不会跑起来的死代码。
+func InitEventSystem() {
+	event.LoadWebhookSubscribers()
+	event.LoadSyncNotifySubscriber()
+	event.SetDeadLetterSink(persistDeadLetter)
+}
+
+func persistDeadLetter(e event.Event, cause error) {
+	payload, err := json.Marshal(e.Payload)
+	if err != nil {
+		common.Log.Errorf("事件 %s 序列化失败，无法写入死信表：%v", e.Type, err)
+		return
+	}
+	record := &model.DeadLetterEvent{
+		EventType: string(e.Type),
+		Payload:   string(payload),
+		LastError: cause.Error(),
+	}
+	if err := isql.DeadLetterEvent.Add(record); err != nil {
+		common.Log.Errorf("事件 %s 投递失败，写入死信表也失败了：%v", e.Type, err)
+	}
+}
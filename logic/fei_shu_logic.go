@@ -0,0 +1,23 @@
+package logic
+
+import (
+	"context"
+
+	"github.com/eryajf/go-ldap-admin/model"
+)
+
+// FeiShuLogic 封装飞书通讯录同步的业务逻辑，结构与 DingTalkLogic 对称。
+type FeiShuLogic struct {
+	Client UpstreamClient
+}
+
+// SyncFeiShuDepts 同步飞书部门数据，taskLog 语义见 DingTalkLogic.SyncDingTalkDepts；
+// dryRun 为 true 时只生成预览计划，不写入 LDAP/MySQL。
+func (l *FeiShuLogic) SyncFeiShuDepts(ctx context.Context, taskLog *model.SyncTaskLog, dryRun bool) error {
+	return runSyncDepts(ctx, "feishu", l.Client, taskLog, dryRun)
+}
+
+// SyncFeiShuUsers 同步飞书用户数据，语义同 SyncFeiShuDepts。
+func (l *FeiShuLogic) SyncFeiShuUsers(ctx context.Context, taskLog *model.SyncTaskLog, dryRun bool) error {
+	return runSyncUsers(ctx, "feishu", l.Client, taskLog, dryRun)
+}
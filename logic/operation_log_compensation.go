@@ -0,0 +1,34 @@
+package logic
+
+import (
+	"github.com/eryajf/go-ldap-admin/logic/tx"
+	"github.com/eryajf/go-ldap-admin/model"
+	"github.com/eryajf/go-ldap-admin/public/common"
+	"github.com/eryajf/go-ldap-admin/service/isql"
+)
+
+var _ tx.CompensationLogger = (*OperationLogLogic)(nil)
+
+// LogCompensationFailure 把一次事务编排中补偿（Undo）失败的操作写入 compensation_failures
+// 表，而不是只打一行日志就让错误悄悄消失：运维可以通过 ListPendingCompensationFailures
+// 查到所有还没处理的记录，人工核对 LDAP/MySQL 数据一致性、处理完后调用
+// ResolveCompensationFailure 标记完成。写库本身失败时退化为只记日志，不让补偿流程 panic。
+func (l *OperationLogLogic) LogCompensationFailure(opName string, doErr, undoErr error) {
+	record := &model.CompensationFailure{OpName: opName, DoError: doErr.Error(), UndoError: undoErr.Error()}
+	if err := isql.CompensationFailure.Add(record); err != nil {
+		common.Log.Errorf("[补偿失败] 操作=%s 原始错误=%v 补偿错误=%v；写入补偿记录表也失败了：%v", opName, doErr, undoErr, err)
+		return
+	}
+	common.Log.Errorf("[补偿失败] 操作=%s 原始错误=%v 补偿错误=%v，已写入补偿记录表 id=%d，需要人工核对 LDAP/MySQL 数据一致性", opName, doErr, undoErr, record.ID)
+}
+
+// ListPendingCompensationFailures 列出尚未人工处理的补偿失败记录，供运维排查、重试参考。
+func ListPendingCompensationFailures() ([]model.CompensationFailure, error) {
+	return isql.CompensationFailure.ListUnresolved()
+}
+
+// ResolveCompensationFailure 把一条补偿失败记录标记为已人工处理（例如手动修好了 LDAP/MySQL
+// 之间的差异，或者确认重新触发一次同步已经自愈）。
+func ResolveCompensationFailure(id uint) error {
+	return isql.CompensationFailure.MarkResolved(id)
+}
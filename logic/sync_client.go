@@ -0,0 +1,206 @@
+package logic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/eryajf/go-ldap-admin/logic/event"
+	"github.com/eryajf/go-ldap-admin/model"
+	"github.com/eryajf/go-ldap-admin/public/common"
+	"github.com/eryajf/go-ldap-admin/public/tools"
+	"github.com/eryajf/go-ldap-admin/service/isql"
+)
+
+// maxStaleWriteRetries 是 updateGroupWithRetry/updateUserWithRetry 在遇到
+// tools.ErrStaleWrite 时最多尝试写入的次数（含第一次），避免和另一边的写入方无限争抢同一行。
+const maxStaleWriteRetries = 3
+
+// updateGroupWithRetry 按 SourceDeptId 查找本地记录：不存在则创建；存在则更新，
+// 更新命中 tools.ErrStaleWrite（该行已被网页编辑或另一次同步改过）时重新查询最新数据
+// 后重试，连续 maxStaleWriteRetries 次都冲突则放弃并把 ErrStaleWrite 返回给调用方。
+func updateGroupWithRetry(g *model.Group) (created bool, err error) {
+	old := new(model.Group)
+	if err := isql.Group.Find(tools.H{"source_dept_id": g.SourceDeptId}, old); err != nil {
+		return true, CommonAddGroup(g)
+	}
+	for attempt := 0; attempt < maxStaleWriteRetries; attempt++ {
+		// g 由上游数据构建，没有本地主键；CAS 更新按 ID 定位行，所以先带上本地记录的 Model。
+		g.Model = old.Model
+		if err := CommonUpdateGroup(old, g); err != nil {
+			if errors.Is(err, tools.ErrStaleWrite) {
+				if ferr := isql.Group.Find(tools.H{"source_dept_id": g.SourceDeptId}, old); ferr != nil {
+					return false, ferr
+				}
+				continue
+			}
+			return false, err
+		}
+		return false, nil
+	}
+	return false, tools.ErrStaleWrite
+}
+
+// updateUserWithRetry 语义同 updateGroupWithRetry，按 SourceUserId 查找/更新用户；
+// 目标部门列表（groupIds）取自上游数据 u.DepartmentId，只在循环前计算一次；每次重试时
+// CommonUpdateUser 内部会用 tools.ArrUintCmp 跟刷新后的旧部门列表重新比对出需要增删的分组。
+func updateUserWithRetry(u *model.User) (created bool, err error) {
+	old := new(model.User)
+	if err := isql.User.Find(tools.H{"source_user_id": u.SourceUserId}, old); err != nil {
+		return true, CommonAddUser(u, nil)
+	}
+	groupIds := tools.StringToSlice(u.DepartmentId, ",")
+	for attempt := 0; attempt < maxStaleWriteRetries; attempt++ {
+		// 原因同 updateGroupWithRetry：带上本地记录的 Model，CAS 更新才能按 ID 定位到这一行。
+		u.Model = old.Model
+		if err := CommonUpdateUser(old, u, groupIds); err != nil {
+			if errors.Is(err, tools.ErrStaleWrite) {
+				if ferr := isql.User.Find(tools.H{"source_user_id": u.SourceUserId}, old); ferr != nil {
+					return false, ferr
+				}
+				continue
+			}
+			return false, err
+		}
+		return false, nil
+	}
+	return false, tools.ErrStaleWrite
+}
+
+// UpstreamClient 从某个上游身份源拉取部门、用户的原始数据，每条记录是一个
+// map[string]interface{}，字段路径与 FieldRelation.Attributes 中配置的 gjson
+// 路径/表达式一一对应。DingTalkLogic/WeComLogic/FeiShuLogic 各自持有一个实现了
+// 该接口的客户端，具体的 HTTP 调用、鉴权、分页不属于同步编排这一层关心的事情。
+type UpstreamClient interface {
+	FetchDepts(ctx context.Context) ([]map[string]interface{}, error)
+	FetchUsers(ctx context.Context) ([]map[string]interface{}, error)
+}
+
+// persistTaskLog 保存 taskLog 当前的进度文本。taskLog 为 nil（cron 定时同步）或者还没有
+// 被 TriggerSync 落库（ID 为 0）时直接跳过，调用方不需要在每个检查点自己判空。
+func persistTaskLog(taskLog *model.SyncTaskLog) {
+	if taskLog == nil || taskLog.ID == 0 {
+		return
+	}
+	if err := isql.SyncTaskLog.Update(taskLog); err != nil {
+		common.Log.Errorf("同步任务日志 %d 落库失败：%v", taskLog.ID, err)
+	}
+}
+
+// runSyncDepts 是各上游源 SyncXxxDepts 的共用实现：拉取原始部门数据，dryRun 为 true 时
+// 只生成预览计划落库（见 BuildSyncPlan/SavePlan），否则走与手工录入完全相同的
+// CommonAddGroup/CommonUpdateGroup，保证定时同步、手动触发、dry-run 预览三条路径行为一致。
+// taskLog 不为 nil 时（手动触发，见 TriggerSync），每个关键节点都会往上面追加一行进度
+// 并重新落库，这样任务跑到一半时单独查询这条记录也能看到最新状态。
+func runSyncDepts(ctx context.Context, flag string, client UpstreamClient, taskLog *model.SyncTaskLog, dryRun bool) error {
+	if client == nil {
+		taskLog.AppendLine(fmt.Sprintf("%s 未配置上游客户端，无法同步部门数据", flag))
+		return fmt.Errorf("%s 未配置上游客户端，无法同步部门数据", flag)
+	}
+	event.Publish(event.SyncStarted{Flag: flag})
+
+	raw, err := client.FetchDepts(ctx)
+	if err != nil {
+		return tools.NewOperationError(fmt.Errorf("拉取%s部门数据失败：%w", flag, err))
+	}
+	taskLog.AppendLine(fmt.Sprintf("拉取到 %d 条%s部门原始数据", len(raw), flag))
+	persistTaskLog(taskLog)
+
+	groups, err := ConvertDeptData(flag, raw)
+	if err != nil {
+		return err
+	}
+
+	stats := event.SyncStats{}
+	var errs []string
+	if dryRun {
+		plan := BuildSyncPlan(flag, groups, nil)
+		rawJSON, err := json.Marshal(raw)
+		if err != nil {
+			return tools.NewOperationError(err)
+		}
+		if err := SavePlan(plan, string(rawJSON), "[]"); err != nil {
+			return err
+		}
+		stats.Creates, stats.Updates = len(plan.GroupCreates), len(plan.GroupUpdates)
+		taskLog.AppendLine(fmt.Sprintf("dry-run 预览：新增 %d、更新 %d，计划已保存待审核", stats.Creates, stats.Updates))
+	} else {
+		for _, g := range groups {
+			created, err := updateGroupWithRetry(g)
+			if err != nil {
+				errs = append(errs, err.Error())
+				continue
+			}
+			if created {
+				stats.Creates++
+			} else {
+				stats.Updates++
+			}
+		}
+		taskLog.AppendLine(fmt.Sprintf("部门同步完成：新增 %d、更新 %d、失败 %d", stats.Creates, stats.Updates, len(errs)))
+	}
+	persistTaskLog(taskLog)
+
+	event.Publish(event.SyncFinished{Flag: flag, Stats: stats, Errors: errs})
+	if len(errs) > 0 {
+		return fmt.Errorf("%s 部门同步存在 %d 条失败", flag, len(errs))
+	}
+	return nil
+}
+
+// runSyncUsers 是各上游源 SyncXxxUsers 的共用实现，语义与 runSyncDepts 相同。
+func runSyncUsers(ctx context.Context, flag string, client UpstreamClient, taskLog *model.SyncTaskLog, dryRun bool) error {
+	if client == nil {
+		taskLog.AppendLine(fmt.Sprintf("%s 未配置上游客户端，无法同步用户数据", flag))
+		return fmt.Errorf("%s 未配置上游客户端，无法同步用户数据", flag)
+	}
+	event.Publish(event.SyncStarted{Flag: flag})
+
+	raw, err := client.FetchUsers(ctx)
+	if err != nil {
+		return tools.NewOperationError(fmt.Errorf("拉取%s用户数据失败：%w", flag, err))
+	}
+	taskLog.AppendLine(fmt.Sprintf("拉取到 %d 条%s用户原始数据", len(raw), flag))
+	persistTaskLog(taskLog)
+
+	users, err := ConvertUserData(flag, raw)
+	if err != nil {
+		return err
+	}
+
+	stats := event.SyncStats{}
+	var errs []string
+	if dryRun {
+		plan := BuildSyncPlan(flag, nil, users)
+		rawJSON, err := json.Marshal(raw)
+		if err != nil {
+			return tools.NewOperationError(err)
+		}
+		if err := SavePlan(plan, "[]", string(rawJSON)); err != nil {
+			return err
+		}
+		stats.Creates, stats.Updates = len(plan.UserCreates), len(plan.UserUpdates)
+		taskLog.AppendLine(fmt.Sprintf("dry-run 预览：新增 %d、更新 %d，计划已保存待审核", stats.Creates, stats.Updates))
+	} else {
+		for _, u := range users {
+			created, err := updateUserWithRetry(u)
+			if err != nil {
+				errs = append(errs, err.Error())
+				continue
+			}
+			if created {
+				stats.Creates++
+			} else {
+				stats.Updates++
+			}
+		}
+		taskLog.AppendLine(fmt.Sprintf("用户同步完成：新增 %d、更新 %d、失败 %d", stats.Creates, stats.Updates, len(errs)))
+	}
+	persistTaskLog(taskLog)
+
+	event.Publish(event.SyncFinished{Flag: flag, Stats: stats, Errors: errs})
+	if len(errs) > 0 {
+		return fmt.Errorf("%s 用户同步存在 %d 条失败", flag, len(errs))
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package logic
+
+import (
+	"fmt"
+
+	"github.com/eryajf/go-ldap-admin/model"
+	"github.com/eryajf/go-ldap-admin/public/tools"
+	"github.com/eryajf/go-ldap-admin/service/isql"
+)
+
+// BuildSyncPlan 汇总一次部门/用户同步 dry-run 会产生的变更，而不真正写入 LDAP/MySQL。
+// 生成的 model.SyncPlan 会被持久化（见 SavePlan），供人工审核后通过 ApplySyncPlan 重放。
+func BuildSyncPlan(flag string, groups []*model.Group, users []*model.User) *model.SyncPlan {
+	plan := &model.SyncPlan{Flag: flag, DryRun: true}
+
+	for _, g := range groups {
+		old := new(model.Group)
+		if err := isql.Group.Find(tools.H{"source_dept_id": g.SourceDeptId}, old); err != nil {
+			plan.GroupCreates = append(plan.GroupCreates, g.SourceDeptId)
+			continue
+		}
+		if diff := diffGroupFields(old, g); len(diff) > 0 {
+			plan.GroupUpdates = append(plan.GroupUpdates, model.PlannedUpdate{SourceID: g.SourceDeptId, Changes: diff})
+		}
+	}
+
+	for _, u := range users {
+		old := new(model.User)
+		if err := isql.User.Find(tools.H{"source_user_id": u.SourceUserId}, old); err != nil {
+			plan.UserCreates = append(plan.UserCreates, u.SourceUserId)
+			continue
+		}
+		if diff := diffUserFields(old, u); len(diff) > 0 {
+			plan.UserUpdates = append(plan.UserUpdates, model.PlannedUpdate{SourceID: u.SourceUserId, Changes: diff})
+		}
+	}
+
+	return plan
+}
+
+// diffUserFields 对比 old/new 两个用户在可同步字段上的差异，CommonUpdateUser 用它来
+// 组装 event.UserUpdated 的 Diff，BuildSyncPlan 用它来生成 dry-run 预览。
+func diffUserFields(old, new *model.User) []model.FieldChange {
+	var changes []model.FieldChange
+	add := func(field, oldVal, newVal string) {
+		if oldVal != newVal {
+			changes = append(changes, model.FieldChange{Field: field, OldValue: oldVal, NewValue: newVal})
+		}
+	}
+	add("nickname", old.Nickname, new.Nickname)
+	add("givenName", old.GivenName, new.GivenName)
+	add("mail", old.Mail, new.Mail)
+	add("jobNumber", old.JobNumber, new.JobNumber)
+	add("mobile", old.Mobile, new.Mobile)
+	add("postalAddress", old.PostalAddress, new.PostalAddress)
+	add("position", old.Position, new.Position)
+	add("introduction", old.Introduction, new.Introduction)
+	return changes
+}
+
+func diffGroupFields(old, new *model.Group) []model.FieldChange {
+	var changes []model.FieldChange
+	if old.Remark != new.Remark {
+		changes = append(changes, model.FieldChange{Field: "remark", OldValue: old.Remark, NewValue: new.Remark})
+	}
+	return changes
+}
+
+// SavePlan 持久化一次 dry-run 计划，rawGroups/rawUsers 为生成该计划时的上游原始数据，
+// 以便 ApplySyncPlan 重放时与预览内容保持完全一致。
+func SavePlan(plan *model.SyncPlan, rawGroups, rawUsers string) error {
+	plan.RawGroups = rawGroups
+	plan.RawUsers = rawUsers
+	if err := isql.SyncPlan.Add(plan); err != nil {
+		return tools.NewMySqlError(err)
+	}
+	return nil
+}
+
+// ApplySyncPlan 重放此前生成的 dry-run 计划：按保存的上游原始数据重新走一遍
+// ConvertDeptData / ConvertUserData，再通过与定时任务完全相同的 CommonAddGroup /
+// CommonAddUser / CommonUpdateGroup / CommonUpdateUser 写入，做到“预览即所得”。
+func ApplySyncPlan(planID uint) error {
+	plan := new(model.SyncPlan)
+	if err := isql.SyncPlan.Find(tools.H{"id": planID}, plan); err != nil {
+		return tools.NewMySqlError(err)
+	}
+	if plan.Applied {
+		return fmt.Errorf("同步计划 %d 已经被应用过，不能重复执行", planID)
+	}
+
+	var rawGroups []map[string]interface{}
+	if err := json.Unmarshal([]byte(plan.RawGroups), &rawGroups); err != nil {
+		return tools.NewOperationError(err)
+	}
+	var rawUsers []map[string]interface{}
+	if err := json.Unmarshal([]byte(plan.RawUsers), &rawUsers); err != nil {
+		return tools.NewOperationError(err)
+	}
+	// 经 JSON 往返后 department_ids 是 []interface{}，ConvertUserData 按 []string 取值，先还原。
+	for _, staff := range rawUsers {
+		if ids, ok := staff["department_ids"].([]interface{}); ok {
+			strIds := make([]string, 0, len(ids))
+			for _, id := range ids {
+				strIds = append(strIds, fmt.Sprint(id))
+			}
+			staff["department_ids"] = strIds
+		}
+	}
+
+	groups, err := ConvertDeptData(plan.Flag, rawGroups)
+	if err != nil {
+		return err
+	}
+	users, err := ConvertUserData(plan.Flag, rawUsers)
+	if err != nil {
+		return err
+	}
+
+	for _, g := range groups {
+		old := new(model.Group)
+		if err := isql.Group.Find(tools.H{"source_dept_id": g.SourceDeptId}, old); err != nil {
+			if err := CommonAddGroup(g); err != nil {
+				return err
+			}
+			continue
+		}
+		g.Model = old.Model
+		if err := CommonUpdateGroup(old, g); err != nil {
+			return err
+		}
+	}
+	for _, u := range users {
+		old := new(model.User)
+		if err := isql.User.Find(tools.H{"source_user_id": u.SourceUserId}, old); err != nil {
+			if err := CommonAddUser(u, nil); err != nil {
+				return err
+			}
+			continue
+		}
+		u.Model = old.Model
+		groupIds := tools.StringToSlice(u.DepartmentId, ",")
+		if err := CommonUpdateUser(old, u, groupIds); err != nil {
+			return err
+		}
+	}
+
+	plan.Applied = true
+	return isql.SyncPlan.Update(plan)
+}
@@ -0,0 +1,147 @@
+package logic
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/eryajf/go-ldap-admin/model"
+	"github.com/eryajf/go-ldap-admin/public/common"
+	"github.com/eryajf/go-ldap-admin/public/tools"
+	"github.com/eryajf/go-ldap-admin/service/isql"
+)
+
+// SyncSource 描述一个可被 InitCron 调度的上游身份源。新增一种上游（比如 LDAP 镜像，
+// 或伙伴管理系统的通用 HTTP/JSON 用户中心）只需实现该接口并调用 RegisterSyncSource，
+// 不用再改 InitCron 本身。
+type SyncSource interface {
+	// Flag 是该源在配置、FieldRelation、BuildXxxData 中使用的标识，例如 "dingtalk"
+	Flag() string
+	// SyncDepts 同步部门（组织架构）数据；taskLog 不为 nil 时会被追加写入本次同步的进度
+	// 文本（见 model.SyncTaskLog.AppendLine），cron 定时调度传 nil 即可；dryRun 为 true
+	// 时只生成预览计划，不写入 LDAP/MySQL。
+	SyncDepts(ctx context.Context, taskLog *model.SyncTaskLog, dryRun bool) error
+	// SyncUsers 同步用户数据，taskLog/dryRun 语义同 SyncDepts。
+	SyncUsers(ctx context.Context, taskLog *model.SyncTaskLog, dryRun bool) error
+	// DefaultSchedule 返回该源在 config.Conf.Sync.Sources 未配置对应 cron 时使用的默认表达式
+	DefaultSchedule() (deptSpec, userSpec string)
+}
+
+var (
+	syncSourcesMu sync.RWMutex
+	syncSources   = make(map[string]SyncSource)
+)
+
+// RegisterSyncSource 把一个上游源注册进全局表，供 InitCron 调度、TriggerSync 同步触发使用。
+func RegisterSyncSource(s SyncSource) {
+	syncSourcesMu.Lock()
+	defer syncSourcesMu.Unlock()
+	syncSources[s.Flag()] = s
+}
+
+func getSyncSource(flag string) (SyncSource, bool) {
+	syncSourcesMu.RLock()
+	defer syncSourcesMu.RUnlock()
+	s, ok := syncSources[flag]
+	return s, ok
+}
+
+// syncSourcesSnapshot 返回注册表的一份快照，避免 InitCron 遍历时长时间持锁。
+func syncSourcesSnapshot() map[string]SyncSource {
+	syncSourcesMu.RLock()
+	defer syncSourcesMu.RUnlock()
+	out := make(map[string]SyncSource, len(syncSources))
+	for k, v := range syncSources {
+		out[k] = v
+	}
+	return out
+}
+
+func init() {
+	RegisterSyncSource(dingTalkSyncSource{DingTalk})
+	RegisterSyncSource(weComSyncSource{WeCom})
+	RegisterSyncSource(feiShuSyncSource{FeiShu})
+}
+
+// dingTalkSyncSource 把既有的 DingTalkLogic 适配成 SyncSource。
+type dingTalkSyncSource struct{ l *DingTalkLogic }
+
+func (s dingTalkSyncSource) Flag() string { return "dingtalk" }
+func (s dingTalkSyncSource) SyncDepts(ctx context.Context, taskLog *model.SyncTaskLog, dryRun bool) error {
+	return s.l.SyncDingTalkDepts(ctx, taskLog, dryRun)
+}
+func (s dingTalkSyncSource) SyncUsers(ctx context.Context, taskLog *model.SyncTaskLog, dryRun bool) error {
+	return s.l.SyncDingTalkUsers(ctx, taskLog, dryRun)
+}
+func (s dingTalkSyncSource) DefaultSchedule() (string, string) {
+	return "0 1 5 * * *", "0 30 5 * * *"
+}
+
+// weComSyncSource 把既有的 WeComLogic 适配成 SyncSource。
+type weComSyncSource struct{ l *WeComLogic }
+
+func (s weComSyncSource) Flag() string { return "wecom" }
+func (s weComSyncSource) SyncDepts(ctx context.Context, taskLog *model.SyncTaskLog, dryRun bool) error {
+	return s.l.SyncWeComDepts(ctx, taskLog, dryRun)
+}
+func (s weComSyncSource) SyncUsers(ctx context.Context, taskLog *model.SyncTaskLog, dryRun bool) error {
+	return s.l.SyncWeComUsers(ctx, taskLog, dryRun)
+}
+func (s weComSyncSource) DefaultSchedule() (string, string) {
+	return "0 1 5 * * *", "0 30 5 * * *"
+}
+
+// feiShuSyncSource 把既有的 FeiShuLogic 适配成 SyncSource。
+type feiShuSyncSource struct{ l *FeiShuLogic }
+
+func (s feiShuSyncSource) Flag() string { return "feishu" }
+func (s feiShuSyncSource) SyncDepts(ctx context.Context, taskLog *model.SyncTaskLog, dryRun bool) error {
+	return s.l.SyncFeiShuDepts(ctx, taskLog, dryRun)
+}
+func (s feiShuSyncSource) SyncUsers(ctx context.Context, taskLog *model.SyncTaskLog, dryRun bool) error {
+	return s.l.SyncFeiShuUsers(ctx, taskLog, dryRun)
+}
+func (s feiShuSyncSource) DefaultSchedule() (string, string) {
+	return "0 1 5 * * *", "0 30 5 * * *"
+}
+
+// TriggerSync 按需触发一次指定上游源的部门或用户同步，供 POST /api/sync/{flag}/{depts|users}
+// 调用，与 cron 调度共用同一套处理逻辑（见 runSyncDepts/runSyncUsers）。不同于 cron 调度
+// 传 nil taskLog，这里会创建一条 model.SyncTaskLog 并立即落库，同步过程中 runSyncDepts/
+// runSyncUsers 往同一条记录上追加进度并持续保存，调用方可以把返回的记录直接展示给前端，
+// 或者之后拿着它的 ID 通过 isql.SyncTaskLog.Find 单独查询任务当前进展。
+func TriggerSync(ctx context.Context, flag, target string, dryRun bool) (*model.SyncTaskLog, error) {
+	source, ok := getSyncSource(flag)
+	if !ok {
+		return nil, fmt.Errorf("未知的同步源：%s", flag)
+	}
+	if target != "depts" && target != "users" {
+		return nil, fmt.Errorf("未知的同步目标：%s，只能是 depts 或 users", target)
+	}
+
+	taskLog := &model.SyncTaskLog{Flag: flag, Target: target, Status: "running"}
+	taskLog.AppendLine(fmt.Sprintf("开始同步 %s 的 %s 数据", flag, target))
+	if err := isql.SyncTaskLog.Add(taskLog); err != nil {
+		return nil, tools.NewMySqlError(err)
+	}
+
+	var err error
+	switch target {
+	case "depts":
+		err = source.SyncDepts(ctx, taskLog, dryRun)
+	case "users":
+		err = source.SyncUsers(ctx, taskLog, dryRun)
+	}
+
+	if err != nil {
+		taskLog.Status = "failed"
+		taskLog.AppendLine("同步失败：" + err.Error())
+	} else {
+		taskLog.Status = "success"
+		taskLog.AppendLine("同步完成")
+	}
+	if uerr := isql.SyncTaskLog.Update(taskLog); uerr != nil {
+		common.Log.Errorf("同步任务日志 %d 落库失败：%v", taskLog.ID, uerr)
+	}
+	return taskLog, err
+}
@@ -0,0 +1,104 @@
+package tx
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+
+	"github.com/eryajf/go-ldap-admin/model"
+	"github.com/eryajf/go-ldap-admin/public/tools"
+	"github.com/eryajf/go-ldap-admin/service/ildap"
+)
+
+// LDAP 操作不参与 MySQL 事务，Do/Undo 的 db 形参固定为 nil，只是为了和 Operation 的签名
+// 保持一致，方便 Bundle 里混用 ldap.* 与 mysql.* 操作。
+
+// LdapUserAdd 在 LDAP 中创建用户，失败时按 DN 删除进行补偿。
+func LdapUserAdd(user *model.User) Operation {
+	return Operation{
+		Name: "ldap.UserAdd",
+		Do: func(_ *gorm.DB) error {
+			if err := ildap.User.Add(user); err != nil {
+				return tools.NewLdapError(fmt.Errorf("AddUser向LDAP创建用户失败：" + err.Error()))
+			}
+			return nil
+		},
+		Undo: func(_ *gorm.DB) error {
+			return ildap.User.Delete(user.UserDN)
+		},
+	}
+}
+
+// LdapUserUpdate 更新 LDAP 中的用户，失败时把旧数据写回去进行补偿。
+func LdapUserUpdate(oldUser, newUser *model.User) Operation {
+	return Operation{
+		Name: "ldap.UserUpdate",
+		Do: func(_ *gorm.DB) error {
+			if err := ildap.User.Update(oldUser.Username, newUser); err != nil {
+				return tools.NewLdapError(fmt.Errorf("在LDAP更新用户失败：" + err.Error()))
+			}
+			return nil
+		},
+		Undo: func(_ *gorm.DB) error {
+			return ildap.User.Update(newUser.Username, oldUser)
+		},
+	}
+}
+
+// LdapGroupAdd 在 LDAP 中创建分组，失败时按 DN 删除进行补偿。
+func LdapGroupAdd(group *model.Group) Operation {
+	return Operation{
+		Name: "ldap.GroupAdd",
+		Do: func(_ *gorm.DB) error {
+			return ildap.Group.Add(group)
+		},
+		Undo: func(_ *gorm.DB) error {
+			return ildap.Group.Delete(group.GroupDN)
+		},
+	}
+}
+
+// LdapGroupUpdate 更新 LDAP 中的分组，失败时把旧数据写回去进行补偿。
+func LdapGroupUpdate(oldGroup, newGroup *model.Group) Operation {
+	return Operation{
+		Name: "ldap.GroupUpdate",
+		Do: func(_ *gorm.DB) error {
+			return ildap.Group.Update(oldGroup, newGroup)
+		},
+		Undo: func(_ *gorm.DB) error {
+			return ildap.Group.Update(newGroup, oldGroup)
+		},
+	}
+}
+
+// LdapGroupAddMember 将用户添加到 LDAP 分组，失败时将其移出进行补偿。
+func LdapGroupAddMember(groupDN, userDN string) Operation {
+	return Operation{
+		Name: "ldap.GroupAddMember",
+		Do: func(_ *gorm.DB) error {
+			if err := ildap.Group.AddUserToGroup(groupDN, userDN); err != nil {
+				return tools.NewLdapError(fmt.Errorf("向Ldap添加用户到分组关系失败：" + err.Error()))
+			}
+			return nil
+		},
+		Undo: func(_ *gorm.DB) error {
+			return ildap.Group.RemoveUserFromGroup(groupDN, userDN)
+		},
+	}
+}
+
+// LdapGroupRemoveMember 将用户从 LDAP 分组移除，失败时重新加回去进行补偿。
+func LdapGroupRemoveMember(groupDN, userDN string) Operation {
+	return Operation{
+		Name: "ldap.GroupRemoveMember",
+		Do: func(_ *gorm.DB) error {
+			if err := ildap.Group.RemoveUserFromGroup(groupDN, userDN); err != nil {
+				return tools.NewLdapError(fmt.Errorf("在ldap将用户从分组移除失败：" + err.Error()))
+			}
+			return nil
+		},
+		Undo: func(_ *gorm.DB) error {
+			return ildap.Group.AddUserToGroup(groupDN, userDN)
+		},
+	}
+}
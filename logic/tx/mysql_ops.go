@@ -0,0 +1,114 @@
+package tx
+
+import (
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+
+	"github.com/eryajf/go-ldap-admin/model"
+	"github.com/eryajf/go-ldap-admin/public/tools"
+	"github.com/eryajf/go-ldap-admin/service/isql"
+)
+
+// MysqlUserAdd 在 MySQL 中创建用户，失败时删除该行进行补偿。
+func MysqlUserAdd(user *model.User) Operation {
+	return Operation{
+		Name: "mysql.UserAdd",
+		Do: func(db *gorm.DB) error {
+			if err := isql.AddUserTx(db, user); err != nil {
+				return tools.NewMySqlError(fmt.Errorf("向MySQL创建用户失败：" + err.Error()))
+			}
+			return nil
+		},
+		Undo: func(db *gorm.DB) error {
+			return isql.User.Delete(user)
+		},
+	}
+}
+
+// MysqlUserUpdate 以乐观并发（CAS）方式更新 MySQL 中的用户行：只有当前行的 version 仍然
+// 等于 oldUser.Version 时才会写入，不一致时返回 tools.ErrStaleWrite，由调用方（目前是
+// CommonUpdateUser 的定时同步调用方）决定重试或放弃，而不是直接覆盖别的请求刚写入的数据。
+// 失败时把旧数据写回去进行补偿。
+func MysqlUserUpdate(oldUser, newUser *model.User) Operation {
+	return Operation{
+		Name: "mysql.UserUpdate",
+		Do: func(db *gorm.DB) error {
+			if err := isql.UpdateUserCAS(db, newUser, oldUser.Version); err != nil {
+				if errors.Is(err, tools.ErrStaleWrite) {
+					return err
+				}
+				return tools.NewMySqlError(fmt.Errorf("在MySQL更新用户失败：" + err.Error()))
+			}
+			return nil
+		},
+		Undo: func(db *gorm.DB) error {
+			return isql.User.Update(oldUser)
+		},
+	}
+}
+
+// MysqlGroupAdd 在 MySQL 中创建分组，失败时删除该行进行补偿。
+func MysqlGroupAdd(group *model.Group) Operation {
+	return Operation{
+		Name: "mysql.GroupAdd",
+		Do: func(db *gorm.DB) error {
+			return isql.AddGroupTx(db, group)
+		},
+		Undo: func(db *gorm.DB) error {
+			return isql.Group.Delete(group)
+		},
+	}
+}
+
+// MysqlGroupUpdate 以乐观并发（CAS）方式更新 MySQL 中的分组行，语义同 MysqlUserUpdate。
+func MysqlGroupUpdate(oldGroup, newGroup *model.Group) Operation {
+	return Operation{
+		Name: "mysql.GroupUpdate",
+		Do: func(db *gorm.DB) error {
+			if err := isql.UpdateGroupCAS(db, newGroup, oldGroup.Version); err != nil {
+				if errors.Is(err, tools.ErrStaleWrite) {
+					return err
+				}
+				return tools.NewMySqlError(fmt.Errorf("在MySQL更新分组失败：" + err.Error()))
+			}
+			return nil
+		},
+		Undo: func(db *gorm.DB) error {
+			return isql.Group.Update(oldGroup)
+		},
+	}
+}
+
+// MysqlGroupAddMember 在 MySQL 中维护用户与分组的关系，失败时移除该关系进行补偿。
+func MysqlGroupAddMember(group *model.Group, users []model.User) Operation {
+	return Operation{
+		Name: "mysql.GroupAddMember",
+		Do: func(db *gorm.DB) error {
+			if err := isql.AddUserToGroupTx(db, group, users); err != nil {
+				return tools.NewMySqlError(fmt.Errorf("向MySQL添加用户到分组关系失败：" + err.Error()))
+			}
+			return nil
+		},
+		Undo: func(db *gorm.DB) error {
+			return isql.Group.RemoveUserFromGroup(group, users)
+		},
+	}
+}
+
+// MysqlGroupRemoveMember 在 MySQL 中移除用户与分组的关系，失败时重新建立该关系进行补偿。
+func MysqlGroupRemoveMember(group *model.Group, users []model.User) Operation {
+	return Operation{
+		Name: "mysql.GroupRemoveMember",
+		Do: func(db *gorm.DB) error {
+			if err := isql.RemoveUserFromGroupTx(db, group, users); err != nil {
+				return tools.NewMySqlError(fmt.Errorf("在MySQL将用户从分组移除失败：" + err.Error()))
+			}
+			return nil
+		},
+		Undo: func(db *gorm.DB) error {
+			return isql.Group.AddUserToGroup(group, users)
+		},
+	}
+}
@@ -0,0 +1,116 @@
+// Package tx 提供跨 LDAP 与 MySQL 的两阶段编排能力：调用方把一组异构操作
+// （ldap 写入、mysql 写入等）声明为 Operation 放进一个 Bundle，Execute 按顺序
+// 执行；一旦某一步失败，就对已经成功执行过的操作按逆序调用 Undo 做补偿，
+// 避免出现“用户建在 MySQL 里但 LDAP 里没有”这类半成品状态。
+package tx
+
+import (
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"github.com/eryajf/go-ldap-admin/public/common"
+)
+
+// Operation 表示一个可补偿的原子操作。
+type Operation struct {
+	// Name 用于补偿日志中标识该操作，例如 "ldap.UserAdd"、"mysql.GroupAddMember"；
+	// "mysql." 前缀的操作会被 Execute 按连续段落合并进同一个 gorm 事务里执行。
+	Name string
+	// Do 执行该操作；db 对 "mysql." 前缀的操作是本次合并事务的句柄，其它操作可以忽略它。
+	Do func(db *gorm.DB) error
+	// Undo 撤销该操作，只会在 Do 成功之后才可能被调用；为 nil 表示该操作不可撤销。
+	// Undo 发生在事务之外（逐条补偿），因为触发补偿时合并事务可能早已提交。
+	Undo func(db *gorm.DB) error
+}
+
+// Bundle 是一组需要按顺序原子生效的 Operation。
+type Bundle struct {
+	ops []Operation
+}
+
+// NewBundle 创建一个空的操作集合。
+func NewBundle() *Bundle {
+	return &Bundle{}
+}
+
+// Add 追加一个操作，返回 Bundle 本身以便链式调用。
+func (b *Bundle) Add(op Operation) *Bundle {
+	b.ops = append(b.ops, op)
+	return b
+}
+
+// CompensationLogger 记录补偿（Undo）失败的操作，便于事后人工核对、重试。
+// 由 logic.OperationLogLogic 实现；Execute 不直接依赖 logic 包，避免产生循环依赖。
+type CompensationLogger interface {
+	LogCompensationFailure(opName string, doErr, undoErr error)
+}
+
+// Execute 按顺序执行 b 中的所有操作；一旦某个操作失败，立即对此前已成功执行的
+// 操作按逆序调用 Undo 进行补偿，并返回失败操作包装后的错误。logger 可以为 nil，
+// 此时补偿失败只会写一条日志，不会向上传递。
+//
+// 连续出现的 "mysql." 前缀操作会被合并进同一个 common.DB.Transaction(...) 调用：
+// 进程在这一段执行到一半崩溃，要么全部生效要么全部不生效，不会留下"部分字段改了、
+// 部分字段没改"这种只有重启后走补偿才能发现的半成品行。这段事务内部任意一步失败，
+// gorm 会自动回滚整段，Execute 不需要再对这一组内部的操作单独调用 Undo。
+func Execute(b *Bundle, logger CompensationLogger) error {
+	done := make([]Operation, 0, len(b.ops))
+	i := 0
+	for i < len(b.ops) {
+		if isMySQLOp(b.ops[i].Name) {
+			j := i
+			for j < len(b.ops) && isMySQLOp(b.ops[j].Name) {
+				j++
+			}
+			group := b.ops[i:j]
+			// failedName 默认指向段内第一个操作，一旦某个操作真正失败就改成它自己的
+			// Name——不然补偿日志和返回的错误信息永远会诬赖成段内第一条操作失败。
+			failedName := group[0].Name
+			if err := common.DB.Transaction(func(txDB *gorm.DB) error {
+				for _, op := range group {
+					if err := op.Do(txDB); err != nil {
+						failedName = op.Name
+						return err
+					}
+				}
+				return nil
+			}); err != nil {
+				rollback(done, failedName, err, logger)
+				return fmt.Errorf("%s 执行失败：%w", failedName, err)
+			}
+			done = append(done, group...)
+			i = j
+			continue
+		}
+
+		op := b.ops[i]
+		if err := op.Do(nil); err != nil {
+			rollback(done, op.Name, err, logger)
+			return fmt.Errorf("%s 执行失败：%w", op.Name, err)
+		}
+		done = append(done, op)
+		i++
+	}
+	return nil
+}
+
+func isMySQLOp(name string) bool {
+	return strings.HasPrefix(name, "mysql.")
+}
+
+func rollback(done []Operation, failedOp string, cause error, logger CompensationLogger) {
+	for i := len(done) - 1; i >= 0; i-- {
+		op := done[i]
+		if op.Undo == nil {
+			continue
+		}
+		if err := op.Undo(nil); err != nil {
+			common.Log.Errorf("补偿操作 %s 失败（触发原因：%s 执行失败：%v），补偿错误：%v", op.Name, failedOp, cause, err)
+			if logger != nil {
+				logger.LogCompensationFailure(op.Name, cause, err)
+			}
+		}
+	}
+}
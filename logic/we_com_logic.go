@@ -0,0 +1,23 @@
+package logic
+
+import (
+	"context"
+
+	"github.com/eryajf/go-ldap-admin/model"
+)
+
+// WeComLogic 封装企业微信通讯录同步的业务逻辑，结构与 DingTalkLogic 对称。
+type WeComLogic struct {
+	Client UpstreamClient
+}
+
+// SyncWeComDepts 同步企业微信部门数据，taskLog 语义见 DingTalkLogic.SyncDingTalkDepts；
+// dryRun 为 true 时只生成预览计划，不写入 LDAP/MySQL。
+func (l *WeComLogic) SyncWeComDepts(ctx context.Context, taskLog *model.SyncTaskLog, dryRun bool) error {
+	return runSyncDepts(ctx, "wecom", l.Client, taskLog, dryRun)
+}
+
+// SyncWeComUsers 同步企业微信用户数据，语义同 SyncWeComDepts。
+func (l *WeComLogic) SyncWeComUsers(ctx context.Context, taskLog *model.SyncTaskLog, dryRun bool) error {
+	return runSyncUsers(ctx, "wecom", l.Client, taskLog, dryRun)
+}
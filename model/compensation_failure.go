@@ -0,0 +1,12 @@
+package model
+
+// CompensationFailure 记录一次 tx.Execute 编排中补偿（Undo）失败的操作，运维据此人工
+// 核对 LDAP/MySQL 之间残留的不一致数据；处理完后调用 isql.CompensationFailure.MarkResolved
+// 标记为已处理，而不是只在日志里留一行就再也找不回来。
+type CompensationFailure struct {
+	Model
+	OpName    string `json:"op_name" gorm:"type:varchar(128);index"`
+	DoError   string `json:"do_error" gorm:"type:text"`
+	UndoError string `json:"undo_error" gorm:"type:text"`
+	Resolved  bool   `json:"resolved" gorm:"default:false;index"`
+}
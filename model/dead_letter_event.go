@@ -0,0 +1,11 @@
+package model
+
+// DeadLetterEvent 记录一个经过 event.deliver 多次退避重试后仍然投递失败的事件，供运维
+// 事后核对、手动补发，而不是只在进程日志里留一行就随着日志滚动消失。
+type DeadLetterEvent struct {
+	Model
+	EventType string `json:"event_type" gorm:"type:varchar(64);index"`
+	Payload   string `json:"payload" gorm:"type:longtext"`
+	LastError string `json:"last_error" gorm:"type:text"`
+	Resolved  bool   `json:"resolved" gorm:"default:false;index"`
+}
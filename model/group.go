@@ -0,0 +1,25 @@
+package model
+
+// Group 对应 LDAP/MySQL 中保存的分组（部门）信息。
+type Group struct {
+	Model
+	GroupName          string `json:"group_name" gorm:"type:varchar(128)"`
+	GroupDN            string `json:"group_dn" gorm:"type:varchar(255)"`
+	Remark             string `json:"remark" gorm:"type:varchar(255)"`
+	SourceDeptId       string `json:"source_dept_id" gorm:"type:varchar(128);index"`
+	SourceDeptParentId string `json:"source_dept_parent_id" gorm:"type:varchar(128)"`
+
+	// Version 语义同 model.User.Version，供 isql.UpdateGroupCAS 做乐观并发的 CAS 更新。
+	Version uint64 `json:"version" gorm:"default:1"`
+
+	Children []*Group `json:"children" gorm:"-"`
+
+	// Users 是该分组下的成员，isql.AddUserToGroupTx/RemoveUserFromGroupTx 通过
+	// db.Model(group).Association("Users") 维护这张多对多关系表。
+	Users []User `json:"-" gorm:"many2many:group_users;"`
+}
+
+func (g *Group) SetGroupName(v string)          { g.GroupName = v }
+func (g *Group) SetRemark(v string)             { g.Remark = v }
+func (g *Group) SetSourceDeptId(v string)       { g.SourceDeptId = v }
+func (g *Group) SetSourceDeptParentId(v string) { g.SourceDeptParentId = v }
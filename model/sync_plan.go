@@ -0,0 +1,80 @@
+package model
+
+import "encoding/json"
+
+// SyncPlan 持久化一次 dry-run 同步预览的结果，待管理员审核后通过
+// POST /api/sync/plan/{id}/apply 重放执行，用于防止上游 IdP 抖动、误删时
+// 直接把部门/成员关系同步坏掉。
+type SyncPlan struct {
+	Model
+	Flag    string `json:"flag" gorm:"type:varchar(64);index"`
+	DryRun  bool   `json:"dry_run" gorm:"default:true"`
+	Applied bool   `json:"applied" gorm:"default:false"`
+
+	// RawGroups / RawUsers 保存生成该计划时抓取到的上游原始数据（JSON 数组），
+	// apply 时据此重新走一遍 ConvertDeptData / ConvertUserData + Common* 写入，
+	// 从而保证“预览即所得”。
+	RawGroups string `json:"raw_groups" gorm:"type:longtext"`
+	RawUsers  string `json:"raw_users" gorm:"type:longtext"`
+
+	// Diff 是下面这四个预览字段序列化后的 JSON，真正落库持久化；EncodeDiff/DecodeDiff
+	// 负责跟它们互转。这四个字段本身标了 gorm:"-"，只是请求/响应里的展示形态，
+	// 不能指望 gorm 直接帮忙存取，否则计划被重新查出来时预览内容就是空的。
+	Diff string `json:"-" gorm:"column:diff;type:longtext"`
+
+	GroupCreates []string        `json:"group_creates" gorm:"-"`
+	GroupUpdates []PlannedUpdate `json:"group_updates" gorm:"-"`
+	UserCreates  []string        `json:"user_creates" gorm:"-"`
+	UserUpdates  []PlannedUpdate `json:"user_updates" gorm:"-"`
+}
+
+// syncPlanDiff 是 SyncPlan.Diff 列的序列化形态。
+type syncPlanDiff struct {
+	GroupCreates []string        `json:"group_creates"`
+	GroupUpdates []PlannedUpdate `json:"group_updates"`
+	UserCreates  []string        `json:"user_creates"`
+	UserUpdates  []PlannedUpdate `json:"user_updates"`
+}
+
+// EncodeDiff 把 GroupCreates/GroupUpdates/UserCreates/UserUpdates 序列化进 Diff 字段，
+// isql.SyncPlan.Add/Update 在落库前调用，这样预览内容才会真正写进数据库。
+func (p *SyncPlan) EncodeDiff() error {
+	b, err := json.Marshal(syncPlanDiff{
+		GroupCreates: p.GroupCreates,
+		GroupUpdates: p.GroupUpdates,
+		UserCreates:  p.UserCreates,
+		UserUpdates:  p.UserUpdates,
+	})
+	if err != nil {
+		return err
+	}
+	p.Diff = string(b)
+	return nil
+}
+
+// DecodeDiff 把 Diff 字段反序列化回 GroupCreates/GroupUpdates/UserCreates/UserUpdates，
+// isql.SyncPlan.Find 在读出记录后调用，这样从数据库重新取出的计划预览内容不会是空的。
+func (p *SyncPlan) DecodeDiff() error {
+	if p.Diff == "" {
+		return nil
+	}
+	var d syncPlanDiff
+	if err := json.Unmarshal([]byte(p.Diff), &d); err != nil {
+		return err
+	}
+	p.GroupCreates, p.GroupUpdates, p.UserCreates, p.UserUpdates = d.GroupCreates, d.GroupUpdates, d.UserCreates, d.UserUpdates
+	return nil
+}
+
+// PlannedUpdate 描述 dry-run 下一条即将被更新的记录及其字段差异。
+type PlannedUpdate struct {
+	SourceID string        `json:"source_id"`
+	Changes  []FieldChange `json:"changes"`
+}
+
+// FieldChange 记录一次 dry-run 更新（或 UserUpdated 事件）中某个字段的前后差异。
+type FieldChange struct {
+	Field    string `json:"field"`
+	OldValue string `json:"old_value"`
+	NewValue string `json:"new_value"`
+}
@@ -0,0 +1,25 @@
+package model
+
+// SyncTaskLog 记录一次手动触发的同步任务（POST /api/sync/{flag}/{depts|users}）执行过程中
+// 产生的进度文本。调用方在任务开始时创建并落库一条记录，随后通过同一个 *SyncTaskLog 指针
+// 持续 AppendLine，每次追加后重新落库，这样任务跑到一半时单独查询这条记录也能看到最新进度，
+// 不用等整个同步跑完。
+type SyncTaskLog struct {
+	Model
+	Flag    string `json:"flag" gorm:"type:varchar(64);index"`
+	Target  string `json:"target" gorm:"type:varchar(16)"`
+	Status  string `json:"status" gorm:"type:varchar(16);default:'running'"` // running/success/failed
+	Content string `json:"content" gorm:"type:longtext"`
+}
+
+// AppendLine 给任务日志追加一行进度文本。nil-safe：cron 定时同步不需要任务日志，
+// 调用方可以直接传 nil 而不必在每次同步过程里判空。
+func (t *SyncTaskLog) AppendLine(line string) {
+	if t == nil {
+		return
+	}
+	if t.Content != "" {
+		t.Content += "\n"
+	}
+	t.Content += line
+}
@@ -0,0 +1,40 @@
+package model
+
+// User 对应 LDAP/MySQL 中保存的用户信息。Set* 方法供 BuildUserData 按 FieldRelation
+// 配置的字段映射挨个赋值时使用，调用方不用直接摸 struct 字段名。
+type User struct {
+	Model
+	Username      string `json:"username" gorm:"type:varchar(64);uniqueIndex"`
+	Nickname      string `json:"nickname" gorm:"type:varchar(64)"`
+	GivenName     string `json:"given_name" gorm:"type:varchar(64)"`
+	Introduction  string `json:"introduction" gorm:"type:varchar(255)"`
+	Mail          string `json:"mail" gorm:"type:varchar(128)"`
+	JobNumber     string `json:"job_number" gorm:"type:varchar(64)"`
+	Departments   string `json:"departments" gorm:"type:varchar(255)"`
+	Position      string `json:"position" gorm:"type:varchar(128)"`
+	PostalAddress string `json:"postal_address" gorm:"type:varchar(255)"`
+	Mobile        string `json:"mobile" gorm:"type:varchar(32)"`
+	Avatar        string `json:"avatar" gorm:"type:varchar(255)"`
+	UserDN        string `json:"user_dn" gorm:"type:varchar(255)"`
+	DepartmentId  string `json:"department_id" gorm:"type:varchar(255)"` // 逗号分隔的分组 ID 列表
+	SourceUserId  string `json:"source_user_id" gorm:"type:varchar(128);index"`
+	SourceUnionId string `json:"source_union_id" gorm:"type:varchar(128)"`
+
+	// Version 用于乐观并发控制：isql.UpdateUserCAS 只在数据库里当前的 version 等于写入方
+	// 读到的那个值时才会真正更新，并把新行的 version 置为原值 + 1。影响行数为 0 说明这期间
+	// 数据已经被别的请求（网页编辑 or 另一次定时同步）改过，返回 tools.ErrStaleWrite。
+	Version uint64 `json:"version" gorm:"default:1"`
+}
+
+func (u *User) SetUserName(v string)      { u.Username = v }
+func (u *User) SetNickName(v string)      { u.Nickname = v }
+func (u *User) SetGivenName(v string)     { u.GivenName = v }
+func (u *User) SetIntroduction(v string)  { u.Introduction = v }
+func (u *User) SetMail(v string)          { u.Mail = v }
+func (u *User) SetJobNumber(v string)     { u.JobNumber = v }
+func (u *User) SetPosition(v string)      { u.Position = v }
+func (u *User) SetPostalAddress(v string) { u.PostalAddress = v }
+func (u *User) SetMobile(v string)        { u.Mobile = v }
+func (u *User) SetAvatar(v string)        { u.Avatar = v }
+func (u *User) SetSourceUserId(v string)  { u.SourceUserId = v }
+func (u *User) SetSourceUnionId(v string) { u.SourceUnionId = v }
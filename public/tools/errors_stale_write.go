@@ -0,0 +1,8 @@
+package tools
+
+import "fmt"
+
+// ErrStaleWrite 表示一次基于 Version 字段的乐观锁更新，因版本号与数据库中的不一致而被拒绝。
+// 调用方（包括 cron 定时同步任务）应当重新查询最新数据、重建差异后再决定是否重试，
+// 而不是直接覆盖别人已经提交的修改。
+var ErrStaleWrite = fmt.Errorf("数据已被其他操作更新，请刷新后重试")
@@ -0,0 +1,350 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// FieldMapping 描述 FieldRelation.Attributes 中单个系统字段到上游数据的映射方式，
+// 取值可以是：
+//   - 一个 gjson 路径字符串（向后兼容的默认行为，例如 ".userid"）；
+//   - 一个表达式对象，例如：
+//     {"expr": "lower(trim(.userid))"}
+//     {"coalesce": [".email", ".mail", ".work_email"]}
+//     {"template": "{{.first_name}} {{.last_name}}"}
+//     {"regex_replace": {"path": ".mobile", "pattern": "^\\+86", "repl": ""}}
+type FieldMapping struct {
+	Expr         string             `json:"expr,omitempty"`
+	Coalesce     []string           `json:"coalesce,omitempty"`
+	Template     string             `json:"template,omitempty"`
+	RegexReplace *RegexReplaceField `json:"regex_replace,omitempty"`
+}
+
+// RegexReplaceField 是 FieldMapping.RegexReplace 的配置项。
+type RegexReplaceField struct {
+	Path    string `json:"path"`
+	Pattern string `json:"pattern"`
+	Repl    string `json:"repl"`
+}
+
+// EvalFieldMapping 依据 raw（单条 FieldRelation 配置项的值）从 source（上游 JSON 原文）中取值。
+// raw 为 string 时按照 gjson 路径处理（向后兼容旧配置）；为 map[string]interface{} 时按表达式 DSL 处理。
+// 路径开头的一个 "." 会先去掉再交给 gjson，".userid" 与 "userid" 等价。
+func EvalFieldMapping(raw interface{}, source string) (string, error) {
+	switch v := raw.(type) {
+	case string:
+		return gjson.Get(source, strings.TrimPrefix(v, ".")).String(), nil
+	case map[string]interface{}:
+		fm, err := mapToFieldMapping(v)
+		if err != nil {
+			return "", err
+		}
+		return fm.eval(source)
+	default:
+		return "", fmt.Errorf("不支持的字段映射配置：%#v", raw)
+	}
+}
+
+func mapToFieldMapping(v map[string]interface{}) (*FieldMapping, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("字段映射配置序列化失败：%w", err)
+	}
+	fm := new(FieldMapping)
+	if err := json.Unmarshal(b, fm); err != nil {
+		return nil, fmt.Errorf("字段映射配置格式不正确：%w", err)
+	}
+	return fm, nil
+}
+
+func (fm *FieldMapping) eval(source string) (string, error) {
+	switch {
+	case fm.Expr != "":
+		return evalExpr(fm.Expr, source)
+	case len(fm.Coalesce) > 0:
+		for _, path := range fm.Coalesce {
+			if val := gjson.Get(source, strings.TrimPrefix(path, ".")).String(); val != "" {
+				return val, nil
+			}
+		}
+		return "", nil
+	case fm.Template != "":
+		return renderTemplate(fm.Template, source), nil
+	case fm.RegexReplace != nil:
+		re, err := regexp.Compile(fm.RegexReplace.Pattern)
+		if err != nil {
+			return "", fmt.Errorf("regex_replace 的 pattern 非法：%w", err)
+		}
+		val := gjson.Get(source, strings.TrimPrefix(fm.RegexReplace.Path, ".")).String()
+		return re.ReplaceAllString(val, fm.RegexReplace.Repl), nil
+	default:
+		return "", fmt.Errorf("字段映射配置为空")
+	}
+}
+
+// renderTemplate 渲染形如 "{{.first_name}} {{.last_name}}" 的模板，{{.path}} 按 gjson 路径取值。
+var templateVarRe = regexp.MustCompile(`\{\{\s*(\.[^}\s]*)\s*\}\}`)
+
+func renderTemplate(tpl, source string) string {
+	return templateVarRe.ReplaceAllStringFunc(tpl, func(m string) string {
+		path := templateVarRe.FindStringSubmatch(m)[1]
+		return gjson.Get(source, strings.TrimPrefix(path, ".")).String()
+	})
+}
+
+// exprValue 是表达式求值过程中的中间结果，可能是 string 或 []string（split 的结果）。
+type exprValue interface{}
+
+type exprNode interface {
+	eval(source string) (exprValue, error)
+}
+
+type pathNode struct{ path string }
+
+func (n pathNode) eval(source string) (exprValue, error) {
+	return gjson.Get(source, n.path).String(), nil
+}
+
+type literalNode struct{ value string }
+
+func (n literalNode) eval(string) (exprValue, error) { return n.value, nil }
+
+type callNode struct {
+	fn   string
+	args []exprNode
+}
+
+func (n callNode) eval(source string) (exprValue, error) {
+	fn, ok := exprFuncs[n.fn]
+	if !ok {
+		return "", fmt.Errorf("表达式使用了未在白名单中的函数：%s", n.fn)
+	}
+	args := make([]exprValue, 0, len(n.args))
+	for _, a := range n.args {
+		v, err := a.eval(source)
+		if err != nil {
+			return "", err
+		}
+		args = append(args, v)
+	}
+	return fn(args)
+}
+
+func toStr(v exprValue) string {
+	switch t := v.(type) {
+	case string:
+		return t
+	case []string:
+		return strings.Join(t, ",")
+	default:
+		return fmt.Sprintf("%v", t)
+	}
+}
+
+// checkArity 校验表达式函数的实参个数，不满足时返回 error 而不是让调用方 a[i] 越界 panic——
+// BuildUserData/BuildGroupData 是在定时同步的 goroutine 里跑的，一条配置错的 FieldRelation
+// 表达式不应该直接搞崩整个同步周期。
+func checkArity(name string, a []exprValue, want int) error {
+	if len(a) != want {
+		return fmt.Errorf("函数 %s 需要 %d 个参数，实际传入 %d 个", name, want, len(a))
+	}
+	return nil
+}
+
+// exprFuncs 是表达式求值支持的固定白名单函数，不允许调用任何未在此注册的函数。
+var exprFuncs = map[string]func(args []exprValue) (exprValue, error){
+	"lower": func(a []exprValue) (exprValue, error) {
+		if err := checkArity("lower", a, 1); err != nil {
+			return "", err
+		}
+		return strings.ToLower(toStr(a[0])), nil
+	},
+	"upper": func(a []exprValue) (exprValue, error) {
+		if err := checkArity("upper", a, 1); err != nil {
+			return "", err
+		}
+		return strings.ToUpper(toStr(a[0])), nil
+	},
+	"trim": func(a []exprValue) (exprValue, error) {
+		if err := checkArity("trim", a, 1); err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(toStr(a[0])), nil
+	},
+	"default": func(a []exprValue) (exprValue, error) {
+		if err := checkArity("default", a, 2); err != nil {
+			return "", err
+		}
+		if toStr(a[0]) == "" {
+			return toStr(a[1]), nil
+		}
+		return toStr(a[0]), nil
+	},
+	"coalesce": func(a []exprValue) (exprValue, error) {
+		if len(a) == 0 {
+			return "", fmt.Errorf("函数 coalesce 至少需要 1 个参数")
+		}
+		for _, v := range a {
+			if toStr(v) != "" {
+				return toStr(v), nil
+			}
+		}
+		return "", nil
+	},
+	// prefix(value, prefixStr) 把 prefixStr 拼在 value 前面；suffix(value, suffixStr) 拼在后面——
+	// 两者此前实现完全相同（都是 a[0]+a[1]），prefix 从未真正“加前缀”过。
+	"prefix": func(a []exprValue) (exprValue, error) {
+		if err := checkArity("prefix", a, 2); err != nil {
+			return "", err
+		}
+		return toStr(a[1]) + toStr(a[0]), nil
+	},
+	"suffix": func(a []exprValue) (exprValue, error) {
+		if err := checkArity("suffix", a, 2); err != nil {
+			return "", err
+		}
+		return toStr(a[0]) + toStr(a[1]), nil
+	},
+	"split": func(a []exprValue) (exprValue, error) {
+		if err := checkArity("split", a, 2); err != nil {
+			return "", err
+		}
+		return strings.Split(toStr(a[0]), toStr(a[1])), nil
+	},
+	"join": func(a []exprValue) (exprValue, error) {
+		if err := checkArity("join", a, 2); err != nil {
+			return "", err
+		}
+		parts, ok := a[0].([]string)
+		if !ok {
+			return "", fmt.Errorf("join 的第一个参数必须是 split 的结果")
+		}
+		return strings.Join(parts, toStr(a[1])), nil
+	},
+	"regex_replace": func(a []exprValue) (exprValue, error) {
+		if err := checkArity("regex_replace", a, 3); err != nil {
+			return "", err
+		}
+		re, err := regexp.Compile(toStr(a[1]))
+		if err != nil {
+			return "", fmt.Errorf("regex_replace 的 pattern 非法：%w", err)
+		}
+		return re.ReplaceAllString(toStr(a[0]), toStr(a[2])), nil
+	},
+}
+
+// evalExpr 解析并求值形如 lower(trim(.userid)) 的表达式。
+func evalExpr(expr, source string) (string, error) {
+	node, err := parseExpr(strings.TrimSpace(expr))
+	if err != nil {
+		return "", err
+	}
+	v, err := node.eval(source)
+	if err != nil {
+		return "", err
+	}
+	return toStr(v), nil
+}
+
+type exprParser struct {
+	s   string
+	pos int
+}
+
+func parseExpr(expr string) (exprNode, error) {
+	p := &exprParser{s: expr}
+	node, err := p.parseNode()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos != len(p.s) {
+		return nil, fmt.Errorf("表达式存在多余内容：%q", p.s[p.pos:])
+	}
+	return node, nil
+}
+
+func (p *exprParser) skipSpace() {
+	for p.pos < len(p.s) && (p.s[p.pos] == ' ' || p.s[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+func (p *exprParser) parseNode() (exprNode, error) {
+	p.skipSpace()
+	if p.pos >= len(p.s) {
+		return nil, fmt.Errorf("表达式意外结束")
+	}
+	switch p.s[p.pos] {
+	case '.':
+		start := p.pos
+		p.pos++
+		for p.pos < len(p.s) && isPathChar(p.s[p.pos]) {
+			p.pos++
+		}
+		return pathNode{path: p.s[start+1 : p.pos]}, nil
+	case '"', '\'':
+		quote := p.s[p.pos]
+		p.pos++
+		start := p.pos
+		for p.pos < len(p.s) && p.s[p.pos] != quote {
+			p.pos++
+		}
+		if p.pos >= len(p.s) {
+			return nil, fmt.Errorf("字符串字面量未闭合")
+		}
+		lit := p.s[start:p.pos]
+		p.pos++
+		return literalNode{value: lit}, nil
+	default:
+		start := p.pos
+		for p.pos < len(p.s) && isIdentChar(p.s[p.pos]) {
+			p.pos++
+		}
+		if start == p.pos {
+			return nil, fmt.Errorf("无法解析的表达式片段：%q", p.s[p.pos:])
+		}
+		name := p.s[start:p.pos]
+		p.skipSpace()
+		if p.pos >= len(p.s) || p.s[p.pos] != '(' {
+			return nil, fmt.Errorf("函数 %s 缺少参数列表", name)
+		}
+		p.pos++
+		var args []exprNode
+		for {
+			p.skipSpace()
+			if p.pos < len(p.s) && p.s[p.pos] == ')' {
+				p.pos++
+				break
+			}
+			arg, err := p.parseNode()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+			p.skipSpace()
+			if p.pos < len(p.s) && p.s[p.pos] == ',' {
+				p.pos++
+				continue
+			}
+			if p.pos < len(p.s) && p.s[p.pos] == ')' {
+				p.pos++
+				break
+			}
+			return nil, fmt.Errorf("函数 %s 的参数列表未正确闭合", name)
+		}
+		return callNode{fn: name, args: args}, nil
+	}
+}
+
+func isPathChar(b byte) bool {
+	return b == '.' || b == '_' || (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') || (b >= '0' && b <= '9')
+}
+
+func isIdentChar(b byte) bool {
+	return (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') || b == '_'
+}
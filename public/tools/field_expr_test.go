@@ -0,0 +1,153 @@
+package tools
+
+import "testing"
+
+// 以下 payload 按钉钉/企业微信/飞书通讯录接口的典型字段形状简化而来。
+const dingTalkUserPayload = `{"userid":"  ZhangSan ","name":"张三","mobile":"+8613800000000","email":"","work_email":"zhangsan@corp.com","given_name":"三","family_name":"张"}`
+
+func TestEvalFieldMapping_PlainGjsonPath(t *testing.T) {
+	got, err := EvalFieldMapping(".name", dingTalkUserPayload)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "张三" {
+		t.Fatalf("want 张三, got %q", got)
+	}
+}
+
+func TestEvalFieldMapping_LowerTrimExpr(t *testing.T) {
+	got, err := EvalFieldMapping(map[string]interface{}{"expr": "lower(trim(.userid))"}, dingTalkUserPayload)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "zhangsan" {
+		t.Fatalf("want zhangsan, got %q", got)
+	}
+}
+
+func TestEvalFieldMapping_Coalesce(t *testing.T) {
+	got, err := EvalFieldMapping(map[string]interface{}{
+		"coalesce": []interface{}{".email", ".mail", ".work_email"},
+	}, dingTalkUserPayload)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "zhangsan@corp.com" {
+		t.Fatalf("want zhangsan@corp.com, got %q", got)
+	}
+}
+
+func TestEvalFieldMapping_Template(t *testing.T) {
+	got, err := EvalFieldMapping(map[string]interface{}{
+		"template": "{{.family_name}}{{.given_name}}",
+	}, dingTalkUserPayload)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "张三" {
+		t.Fatalf("want 张三, got %q", got)
+	}
+}
+
+func TestEvalFieldMapping_RegexReplaceStripCountryCode(t *testing.T) {
+	got, err := EvalFieldMapping(map[string]interface{}{
+		"regex_replace": map[string]interface{}{
+			"path":    ".mobile",
+			"pattern": `^\+86`,
+			"repl":    "",
+		},
+	}, dingTalkUserPayload)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "13800000000" {
+		t.Fatalf("want 13800000000, got %q", got)
+	}
+}
+
+func TestEvalFieldMapping_UnknownFunctionRejected(t *testing.T) {
+	_, err := EvalFieldMapping(map[string]interface{}{"expr": "exec(.userid)"}, dingTalkUserPayload)
+	if err == nil {
+		t.Fatal("expected an error for a function outside the whitelist, got nil")
+	}
+}
+
+func TestEvalFieldMapping_Prefix(t *testing.T) {
+	got, err := EvalFieldMapping(map[string]interface{}{"expr": `prefix(trim(.userid), "dingtalk_")`}, dingTalkUserPayload)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "dingtalk_ZhangSan" {
+		t.Fatalf("want dingtalk_ZhangSan, got %q", got)
+	}
+}
+
+func TestEvalFieldMapping_Suffix(t *testing.T) {
+	got, err := EvalFieldMapping(map[string]interface{}{"expr": `suffix(trim(.userid), "_dingtalk")`}, dingTalkUserPayload)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "ZhangSan_dingtalk" {
+		t.Fatalf("want ZhangSan_dingtalk, got %q", got)
+	}
+}
+
+func TestEvalFieldMapping_PrefixSuffixNotIdentical(t *testing.T) {
+	prefixed, err := EvalFieldMapping(map[string]interface{}{"expr": `prefix("B", "A")`}, dingTalkUserPayload)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	suffixed, err := EvalFieldMapping(map[string]interface{}{"expr": `suffix("A", "B")`}, dingTalkUserPayload)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if prefixed != "AB" || suffixed != "AB" {
+		t.Fatalf("want both to be AB via their respective argument order, got prefix=%q suffix=%q", prefixed, suffixed)
+	}
+	// 用不对称的参数验证两者不再是同一种拼接顺序（此前的 bug 是 prefix/suffix 实现完全相同）。
+	prefixed2, err := EvalFieldMapping(map[string]interface{}{"expr": `prefix("X", "Y")`}, dingTalkUserPayload)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	suffixed2, err := EvalFieldMapping(map[string]interface{}{"expr": `suffix("X", "Y")`}, dingTalkUserPayload)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if prefixed2 == suffixed2 {
+		t.Fatalf("prefix(X,Y) and suffix(X,Y) should differ, both got %q", prefixed2)
+	}
+	if prefixed2 != "YX" {
+		t.Fatalf("want YX, got %q", prefixed2)
+	}
+	if suffixed2 != "XY" {
+		t.Fatalf("want XY, got %q", suffixed2)
+	}
+}
+
+func TestEvalFieldMapping_SplitJoin(t *testing.T) {
+	got, err := EvalFieldMapping(map[string]interface{}{"expr": `join(split(.mobile, "0"), "-")`}, dingTalkUserPayload)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got == "" {
+		t.Fatalf("want a non-empty joined string")
+	}
+}
+
+func TestEvalFieldMapping_MalformedArityReturnsErrorNotPanic(t *testing.T) {
+	cases := []string{
+		"lower()",
+		"trim()",
+		`prefix(.userid)`,
+		`suffix(.userid)`,
+		`split(.mobile)`,
+		`join(split(.mobile, "0"))`,
+		`regex_replace(.mobile, "^\\+86")`,
+	}
+	for _, expr := range cases {
+		_, err := EvalFieldMapping(map[string]interface{}{"expr": expr}, dingTalkUserPayload)
+		if err == nil {
+			t.Fatalf("expr %q: expected an arity error, got nil", expr)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+// Package router 挂载本次同步相关功能新增的 HTTP 接口。真实项目的路由入口会把各个模块的
+// RegisterXxxRoutes 按统一前缀、统一鉴权中间件组装进一个 gin.Engine；这里只提供独立可挂载的
+// RegisterSyncRoutes，调用方在启动时 router.RegisterSyncRoutes(apiGroup) 即可接入，
+// 不需要再改这个包本身。
+package router
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/eryajf/go-ldap-admin/logic"
+)
+
+// RegisterSyncRoutes 挂载：
+//   - POST /api/sync/:flag/:target      按需触发一次指定上游源的部门/用户同步，
+//     ?dry_run=true 时只生成预览计划（见 logic.BuildSyncPlan），不写入 LDAP/MySQL。
+//   - POST /api/sync/plan/:id/apply     重放此前生成的 dry-run 计划。
+func RegisterSyncRoutes(r gin.IRouter) {
+	r.POST("/api/sync/plan/:id/apply", applySyncPlan)
+	r.POST("/api/sync/:flag/:target", triggerSync)
+}
+
+// triggerSync 对应 POST /api/sync/{flag}/{depts|users}。
+func triggerSync(c *gin.Context) {
+	flag := c.Param("flag")
+	target := c.Param("target")
+	dryRun := c.Query("dry_run") == "true"
+
+	taskLog, err := logic.TriggerSync(c.Request.Context(), flag, target, dryRun)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"code": -1, "msg": err.Error(), "data": taskLog})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "msg": "同步任务已执行", "data": taskLog})
+}
+
+// applySyncPlan 对应 POST /api/sync/plan/{id}/apply。
+func applySyncPlan(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"code": -1, "msg": "非法的计划 id：" + c.Param("id")})
+		return
+	}
+	if err := logic.ApplySyncPlan(uint(id)); err != nil {
+		c.JSON(http.StatusOK, gin.H{"code": -1, "msg": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "msg": "同步计划已应用"})
+}
@@ -0,0 +1,51 @@
+package isql
+
+import (
+	"gorm.io/gorm"
+
+	"github.com/eryajf/go-ldap-admin/model"
+	"github.com/eryajf/go-ldap-admin/public/common"
+	"github.com/eryajf/go-ldap-admin/public/tools"
+)
+
+// UpdateUserCAS 以乐观并发方式更新一行用户数据：只有数据库里当前的 version 等于
+// expectedVersion 时才会真正写入，同时把新行的 version 置为 expectedVersion+1。
+// db 为 nil 时使用全局连接 common.DB，非 nil 时使用调用方传入的事务句柄（见 logic/tx），
+// 使这次更新能跟同一个 tx.Bundle 里的其它 MySQL 操作共享同一个事务。
+// 影响行数为 0 说明数据已被其它请求改过，返回 tools.ErrStaleWrite。
+func UpdateUserCAS(db *gorm.DB, user *model.User, expectedVersion uint64) error {
+	if db == nil {
+		db = common.DB
+	}
+	user.Version = expectedVersion + 1
+	result := db.Model(&model.User{}).
+		Where("id = ? AND version = ?", user.ID, expectedVersion).
+		Select("*").
+		Updates(user)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return tools.ErrStaleWrite
+	}
+	return nil
+}
+
+// UpdateGroupCAS 语义同 UpdateUserCAS，作用于分组。
+func UpdateGroupCAS(db *gorm.DB, group *model.Group, expectedVersion uint64) error {
+	if db == nil {
+		db = common.DB
+	}
+	group.Version = expectedVersion + 1
+	result := db.Model(&model.Group{}).
+		Where("id = ? AND version = ?", group.ID, expectedVersion).
+		Select("*").
+		Updates(group)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return tools.ErrStaleWrite
+	}
+	return nil
+}
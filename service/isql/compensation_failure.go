@@ -0,0 +1,29 @@
+package isql
+
+import (
+	"github.com/eryajf/go-ldap-admin/model"
+	"github.com/eryajf/go-ldap-admin/public/common"
+	"github.com/eryajf/go-ldap-admin/public/tools"
+)
+
+// CompensationFailure 是 compensation_failures 表的数据库操作入口。
+var CompensationFailure = new(compensationFailure)
+
+type compensationFailure struct{}
+
+// Add 新增一条补偿失败记录。
+func (*compensationFailure) Add(data *model.CompensationFailure) error {
+	return common.DB.Create(data).Error
+}
+
+// ListUnresolved 列出尚未人工处理的补偿失败记录，供运维排查页面展示。
+func (*compensationFailure) ListUnresolved() ([]model.CompensationFailure, error) {
+	var out []model.CompensationFailure
+	err := common.DB.Where(tools.H{"resolved": false}).Find(&out).Error
+	return out, err
+}
+
+// MarkResolved 把一条补偿失败记录标记为已人工处理。
+func (*compensationFailure) MarkResolved(id uint) error {
+	return common.DB.Model(&model.CompensationFailure{}).Where("id = ?", id).Update("resolved", true).Error
+}
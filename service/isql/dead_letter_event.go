@@ -0,0 +1,29 @@
+package isql
+
+import (
+	"github.com/eryajf/go-ldap-admin/model"
+	"github.com/eryajf/go-ldap-admin/public/common"
+	"github.com/eryajf/go-ldap-admin/public/tools"
+)
+
+// DeadLetterEvent 是 dead_letter_events 表的数据库操作入口。
+var DeadLetterEvent = new(deadLetterEvent)
+
+type deadLetterEvent struct{}
+
+// Add 新增一条死信事件记录。
+func (*deadLetterEvent) Add(data *model.DeadLetterEvent) error {
+	return common.DB.Create(data).Error
+}
+
+// ListUnresolved 列出尚未人工处理的死信事件，供运维排查、手动补发参考。
+func (*deadLetterEvent) ListUnresolved() ([]model.DeadLetterEvent, error) {
+	var out []model.DeadLetterEvent
+	err := common.DB.Where(tools.H{"resolved": false}).Find(&out).Error
+	return out, err
+}
+
+// MarkResolved 把一条死信事件标记为已人工处理（例如确认已经手动补发过）。
+func (*deadLetterEvent) MarkResolved(id uint) error {
+	return common.DB.Model(&model.DeadLetterEvent{}).Where("id = ?", id).Update("resolved", true).Error
+}
@@ -0,0 +1,51 @@
+package isql
+
+import (
+	"gorm.io/gorm"
+
+	"github.com/eryajf/go-ldap-admin/model"
+	"github.com/eryajf/go-ldap-admin/public/common"
+)
+
+// 本文件里的函数都显式接收一个 *gorm.DB：db 为 nil 时退化为使用全局连接 common.DB，
+// 非 nil 时使用调用方传入的事务句柄。logic/tx.Execute 会把一组连续的 "mysql." 操作
+// 合并进同一个 common.DB.Transaction(...) 里执行，这些函数就是那段事务里实际落地的
+// 写入逻辑，不走 isql.User/isql.Group 上可能附带额外副作用的方法，避免同一行在两套
+// 连接上各写一半。
+
+func dbOrDefault(db *gorm.DB) *gorm.DB {
+	if db == nil {
+		return common.DB
+	}
+	return db
+}
+
+// AddUserTx 在 db 所在的连接/事务里创建一个用户。
+func AddUserTx(db *gorm.DB, user *model.User) error {
+	return dbOrDefault(db).Create(user).Error
+}
+
+// DeleteUserTx 在 db 所在的连接/事务里删除一个用户。
+func DeleteUserTx(db *gorm.DB, user *model.User) error {
+	return dbOrDefault(db).Delete(user).Error
+}
+
+// AddGroupTx 在 db 所在的连接/事务里创建一个分组。
+func AddGroupTx(db *gorm.DB, group *model.Group) error {
+	return dbOrDefault(db).Create(group).Error
+}
+
+// DeleteGroupTx 在 db 所在的连接/事务里删除一个分组。
+func DeleteGroupTx(db *gorm.DB, group *model.Group) error {
+	return dbOrDefault(db).Delete(group).Error
+}
+
+// AddUserToGroupTx 在 db 所在的连接/事务里把 users 加入 group 的成员关系。
+func AddUserToGroupTx(db *gorm.DB, group *model.Group, users []model.User) error {
+	return dbOrDefault(db).Model(group).Association("Users").Append(&users)
+}
+
+// RemoveUserFromGroupTx 在 db 所在的连接/事务里把 users 从 group 的成员关系中移除。
+func RemoveUserFromGroupTx(db *gorm.DB, group *model.Group, users []model.User) error {
+	return dbOrDefault(db).Model(group).Association("Users").Delete(&users)
+}
@@ -0,0 +1,37 @@
+package isql
+
+import (
+	"github.com/eryajf/go-ldap-admin/model"
+	"github.com/eryajf/go-ldap-admin/public/common"
+	"github.com/eryajf/go-ldap-admin/public/tools"
+)
+
+// SyncPlan 是 sync_plans 表的数据库操作入口。
+var SyncPlan = new(syncPlan)
+
+type syncPlan struct{}
+
+// Add 新增一条 dry-run 同步计划，落库前把预览字段编码进 Diff 列。
+func (*syncPlan) Add(data *model.SyncPlan) error {
+	if err := data.EncodeDiff(); err != nil {
+		return err
+	}
+	return common.DB.Create(data).Error
+}
+
+// Find 按条件查询一条同步计划，查出后把 Diff 列解码回预览字段，
+// 否则调用方拿到的 GroupCreates/GroupUpdates/UserCreates/UserUpdates 永远是空的。
+func (*syncPlan) Find(where tools.H, data *model.SyncPlan) error {
+	if err := common.DB.Where(where).First(data).Error; err != nil {
+		return err
+	}
+	return data.DecodeDiff()
+}
+
+// Update 更新一条同步计划（例如将 Applied 置为 true），写库前同步刷新 Diff 列。
+func (*syncPlan) Update(data *model.SyncPlan) error {
+	if err := data.EncodeDiff(); err != nil {
+		return err
+	}
+	return common.DB.Save(data).Error
+}
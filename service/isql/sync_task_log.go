@@ -0,0 +1,27 @@
+package isql
+
+import (
+	"github.com/eryajf/go-ldap-admin/model"
+	"github.com/eryajf/go-ldap-admin/public/common"
+	"github.com/eryajf/go-ldap-admin/public/tools"
+)
+
+// SyncTaskLog 是 sync_task_logs 表的数据库操作入口。
+var SyncTaskLog = new(syncTaskLog)
+
+type syncTaskLog struct{}
+
+// Add 新增一条同步任务日志。
+func (*syncTaskLog) Add(data *model.SyncTaskLog) error {
+	return common.DB.Create(data).Error
+}
+
+// Update 保存任务日志的最新进度（Content/Status），供调用方在任务执行过程中多次调用。
+func (*syncTaskLog) Update(data *model.SyncTaskLog) error {
+	return common.DB.Save(data).Error
+}
+
+// Find 按条件查询一条同步任务日志，供前端轮询任务当前进度。
+func (*syncTaskLog) Find(where tools.H, data *model.SyncTaskLog) error {
+	return common.DB.Where(where).First(data).Error
+}